@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/module"
+	"moehl.dev/go-update/internal"
+)
+
+// checkArchived looks up whether the repositories of binaries hosted on GitHub
+// or GitLab have been archived. It is opt-in, as it needs a request per
+// repository and the GitHub API has a low rate limit.
+var checkArchived bool
+
+// gitlabAPI is the base URL of the GitLab REST API.
+const gitlabAPI = "https://gitlab.com/api/v4"
+
+// checkArchivedEnabled reports whether archived repositories are looked up.
+func checkArchivedEnabled() bool {
+	return checkArchived || cfg.CheckArchived
+}
+
+var (
+	archivedMu sync.Mutex
+	// archivedCache maps module paths without major version suffix to the URL
+	// of their repository if it is archived, or an empty string if it is
+	// not, as multiple binaries may be built from the same module.
+	archivedCache = make(map[string]string)
+	// archivedRepos maps the executable paths of binaries to the URL of their
+	// archived repository.
+	archivedRepos = make(map[string]string)
+)
+
+// lookupArchived records and returns the URL of the repository of the binary
+// if it has been archived. It returns an empty string if the repository is
+// not archived or not hosted on a supported forge.
+func lookupArchived(ctx context.Context, a Artefact) (string, error) {
+	if _, ok := a.(*binary); !ok {
+		return "", nil
+	}
+
+	repoURL, err := repoArchived(ctx, a.ModulePath())
+	if err != nil || repoURL == "" {
+		return "", err
+	}
+
+	archivedMu.Lock()
+	archivedRepos[a.ExecutablePath()] = repoURL
+	archivedMu.Unlock()
+	return repoURL, nil
+}
+
+// archivedLabel returns the URL of the archived repository of the artefact.
+func archivedLabel(a Artefact) string {
+	archivedMu.Lock()
+	defer archivedMu.Unlock()
+	return archivedRepos[a.ExecutablePath()]
+}
+
+// repoArchived returns the URL of the repository of the module if it is
+// archived.
+func repoArchived(ctx context.Context, modulePath string) (string, error) {
+	prefix, _, _ := module.SplitPathVersion(modulePath)
+	host, _, _ := strings.Cut(prefix, "/")
+
+	var lookup func(ctx context.Context, prefix string) (string, bool, error)
+	switch host {
+	case "github.com":
+		lookup = githubArchived
+	case "gitlab.com":
+		lookup = gitlabArchived
+	default:
+		return "", nil
+	}
+
+	archivedMu.Lock()
+	repoURL, ok := archivedCache[prefix]
+	archivedMu.Unlock()
+	if ok {
+		return repoURL, nil
+	}
+
+	repoURL, archived, err := lookup(ctx, prefix)
+	if err != nil {
+		return "", err
+	}
+	if !archived {
+		repoURL = ""
+	}
+
+	archivedMu.Lock()
+	archivedCache[prefix] = repoURL
+	archivedMu.Unlock()
+	return repoURL, nil
+}
+
+func githubArchived(ctx context.Context, prefix string) (string, bool, error) {
+	owner, repo, _, ok := githubRepo(prefix)
+	if !ok {
+		return "", false, nil
+	}
+
+	body, err := githubGet(ctx, fmt.Sprintf("%s/repos/%s/%s", githubAPI, url.PathEscape(owner), url.PathEscape(repo)))
+	if err != nil {
+		return "", false, err
+	}
+
+	var r struct {
+		Archived bool   `json:"archived"`
+		HTMLURL  string `json:"html_url"`
+	}
+	err = json.Unmarshal(body, &r)
+	return r.HTMLURL, r.Archived, err
+}
+
+// gitlabArchived looks up the project of the module on GitLab. Projects may be
+// nested in groups and modules in directories of a project, so the longest
+// path naming a project is used.
+func gitlabArchived(ctx context.Context, prefix string) (string, bool, error) {
+	parts := strings.Split(prefix, "/")
+	for n := len(parts); n >= 3; n-- {
+		project := strings.Join(parts[1:n], "/")
+
+		var r struct {
+			Archived bool   `json:"archived"`
+			WebURL   string `json:"web_url"`
+		}
+		body, err := gitlabGet(ctx, gitlabAPI+"/projects/"+url.PathEscape(project))
+		var statusErr *internal.StatusError
+		if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+			continue
+		} else if err != nil {
+			return "", false, err
+		}
+
+		err = json.Unmarshal(body, &r)
+		return r.WebURL, r.Archived, err
+	}
+	return "", false, nil
+}
+
+// gitlabGet requests the GitLab API, transient errors are retried. Public
+// projects don't require authentication.
+func gitlabGet(ctx context.Context, u string) ([]byte, error) {
+	var body []byte
+	err := internal.Retry(ctx, u, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = res.Body.Close() }()
+
+		if res.StatusCode != http.StatusOK {
+			return &internal.StatusError{URL: u, Status: res.Status, Code: res.StatusCode}
+		}
+
+		body, err = io.ReadAll(res.Body)
+		return err
+	})
+	return body, err
+}
@@ -1,145 +1,400 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"runtime"
 	"runtime/debug"
-	"strings"
+	"sync"
 
+	"golang.org/x/mod/module"
 	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
 )
 
-type Artefact interface {
-	// ModulePath to look up available versions of the module.
-	ModulePath() string
+// Artefact is implemented by binaries and go toolchains, see update.Artefact.
+type Artefact = update.Artefact
 
-	// InstallPath is the module path plus the path to the main package inside
-	// the module. It can be used by `go install` together with a version.
-	InstallPath() string
+// ArtefactOptions control how the target version of an artefact is resolved,
+// see update.Options.
+type ArtefactOptions = update.Options
 
-	// InstalledVersion is the currently installed version of the binary.
-	InstalledVersion() string
+// The kinds of artefacts go-update knows about. Go toolchain wrappers are
+// registered last, so they take precedence over the binaries matching all
+// build infos.
+func init() {
+	update.RegisterArtefact(update.ArtefactKind{
+		Name:  "binary",
+		Match: func(*debug.BuildInfo) bool { return true },
+		New:   newBinary,
+	})
+	update.RegisterArtefact(update.ArtefactKind{
+		Name:  "go toolchain",
+		Match: func(bi *debug.BuildInfo) bool { return bi.Main.Path == update.ToolchainModule },
+		New:   newGoToolchain,
+	})
+}
 
-	// TargetVersion that should be installed.
-	TargetVersion() string
+// isDevel reports whether the artefact is a development build.
+func isDevel(a Artefact) bool {
+	return a.InstalledVersion() == update.DevelVersion
+}
 
-	// NeedsUpdate returns whether the artefact should be updated.
-	NeedsUpdate() bool
+// isDowngrade reports whether the target version of the artefact is older than
+// the installed one.
+func isDowngrade(a Artefact) bool {
+	return update.IsDowngrade(a.InstalledVersion(), a.TargetVersion())
+}
 
-	// Update installs the target version of the binary.
-	Update() error
+// replaced returns the replace directives of the artefact, only binaries can
+// have them.
+func replaced(a Artefact) []string {
+	if b, ok := a.(*binary); ok {
+		return b.res.Replaced
+	}
+	return nil
 }
 
-func NewArtefact(bi *debug.BuildInfo) (Artefact, error) {
-	if bi == nil {
-		return nil, fmt.Errorf("build info is nil")
+// builtWith returns the go version the artefact has been built with.
+func builtWith(a Artefact) string {
+	switch a := a.(type) {
+	case *binary:
+		return a.GoVersion
+	case *goToolchain:
+		return a.goVersion
 	}
+	return ""
+}
 
-	if bi.Main.Path == "golang.org/dl" {
-		return newGoToolchain(*bi)
-	} else {
-		return newBinary(*bi)
+// installedVersion returns the version of the artefact described by bi without
+// resolving its target version.
+func installedVersion(bi *debug.BuildInfo) string {
+	if bi.Main.Path == update.ToolchainModule {
+		return path.Base(bi.Path)
 	}
+	return bi.Main.Version
 }
 
+// binary is a Go binary installed with go install, its target version is
+// resolved by update.Resolver.
 type binary struct {
 	debug.BuildInfo
 
-	targetVersion string
-	args          []string
-	env           []string
+	executablePath string
+	res            *update.Resolution
+	// opts are the build flags and environment the binary has been built with.
+	opts update.InstallOptions
+	// allowReplaced permits rebuilding a binary built with replace directives.
+	allowReplaced bool
 }
 
-func newBinary(bi debug.BuildInfo) (Artefact, error) {
-	versions, err := internal.ListVersions(bi.Main.Path)
-	if err != nil {
-		return nil, err
+func newBinary(ctx context.Context, executablePath string, bi *debug.BuildInfo, opts ArtefactOptions) (Artefact, error) {
+	if module.IsPseudoVersion(bi.Main.Version) {
+		opts.Branch = trackedBranch(executablePath, *bi, opts)
 	}
 
-	if len(versions) == 0 {
-		return nil, fmt.Errorf("go list did not return any version")
+	res, err := update.Resolver{Options: opts}.Resolve(ctx, bi)
+	if err != nil {
+		return nil, err
 	}
 
 	return &binary{
-		BuildInfo:     bi,
-		targetVersion: versions[len(versions)-1],
+		BuildInfo:      *bi,
+		executablePath: executablePath,
+		res:            res,
+		opts:           buildSettings(executablePath, *bi),
+		allowReplaced:  opts.AllowReplaced,
 	}, nil
 }
 
-func (b *binary) ModulePath() string       { return b.Main.Path }
-func (b *binary) InstallPath() string      { return b.Path }
+func (b *binary) ExecutablePath() string   { return b.executablePath }
+func (b *binary) ModulePath() string       { return b.res.ModulePath }
+func (b *binary) InstallPath() string      { return b.res.PackagePath }
 func (b *binary) InstalledVersion() string { return b.Main.Version }
-func (b *binary) TargetVersion() string    { return b.targetVersion }
-func (b *binary) NeedsUpdate() bool        { return b.targetVersion != b.InstalledVersion() }
-func (b *binary) Update() error            { return internal.Install(b.InstallPath(), b.TargetVersion()) }
+func (b *binary) TargetVersion() string    { return b.res.Target }
+func (b *binary) Pinned() bool             { return b.res.Pinned }
+func (b *binary) Retracted() string        { return b.res.Retracted }
+func (b *binary) Deprecated() string       { return b.res.Deprecated }
+func (b *binary) MajorUpdate() string      { return b.res.MajorUpdate() }
+func (b *binary) NeedsUpdate() bool        { return b.res.NeedsUpdate() }
+
+func (b *binary) Update(ctx context.Context) error {
+	return b.install(ctx, b.InstallPath(), b.TargetVersion())
+}
+
+// rebuild installs the installed version again, e.g. to build it with a newer
+// go version.
+func (b *binary) rebuild(ctx context.Context) error {
+	return b.install(ctx, b.Path, b.InstalledVersion())
+}
+
+// install replaces the binary with the given version of the package. It is
+// installed into a staging directory next to the binary and checked first, so
+// a failed or interrupted build leaves the binary untouched.
+func (b *binary) install(ctx context.Context, pkg, version string) error {
+	opts := b.opts.WithVersion(b.InstalledVersion(), version)
+
+	if dryRun {
+		commands := []string{
+			fmt.Sprintf("GOBIN=<staging> %s", opts.Command(pkg, version)),
+			fmt.Sprintf("mv <staging>/* %s", b.executablePath),
+		}
+		if smokeTestEnabled() {
+			commands = append(commands, probeCommand(b.executablePath))
+		}
+		printDryRun(commands...)
+		return nil
+	}
 
+	// installed is set once the binary has been replaced, copies of it
+	// installed with the same command wait for it.
+	installed := ""
+	defer func() { finishBuild(installed, pkg, version, opts) }()
+
+	// The staging directory has to be on the same file system as the binary,
+	// so it can be replaced atomically. This also installs binaries from
+	// additional bin directories back into them.
+	staged, cleanup, err := stageBuild(ctx, filepath.Dir(b.executablePath), pkg, version, opts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	err = backup(b.executablePath, b.InstalledVersion())
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	err = replaceWithStaged(staged, b.executablePath)
+	if err != nil {
+		return err
+	}
+
+	if smokeTestEnabled() {
+		err = runSmokeTest(ctx, b.executablePath)
+		if err != nil {
+			restoreErr := restoreBackup(backupPath(b.executablePath, b.InstalledVersion()), b.executablePath)
+			if restoreErr != nil {
+				return fmt.Errorf("%w, restoring %s failed: %w", err, b.InstalledVersion(), restoreErr)
+			}
+			return fmt.Errorf("%w, restored %s", err, b.InstalledVersion())
+		}
+	}
+
+	recordInstall(b.executablePath, pkg, version, b.res.Branch, opts)
+	installed = b.executablePath
+
+	return nil
+}
+
+// toolchainMu serializes toolchain updates, as all of them replace the same
+// go symlink.
+var toolchainMu sync.Mutex
+
+// goToolchain is a wrapper installed from golang.org/dl. The toolchain the go
+// link in GOBIN points to is updated to the latest release, or the latest one
+// in the configured series. All other toolchains stay on their own series, so
+// multiple minor versions can be installed side by side.
 type goToolchain struct {
 	executablePath   string
 	installedVersion string
 	targetVersion    string
+	pinned           bool
+	// goVersion is the go version the wrapper has been built with.
+	goVersion string
+	// linked is set if the go link points to the toolchain.
+	linked bool
+	// archive is the SDK archive of the target version, its SHA256 sum is
+	// verified after the download.
+	archive *goReleaseFile
 }
 
-func newGoToolchain(bi debug.BuildInfo) (Artefact, error) {
-	a := &goToolchain{}
+func newGoToolchain(ctx context.Context, executablePath string, bi *debug.BuildInfo, opts ArtefactOptions) (Artefact, error) {
+	a := &goToolchain{executablePath: executablePath}
 
 	if bi.Main.Path != a.ModulePath() {
 		return nil, fmt.Errorf("build info is not a go toolchain")
 	}
 
 	a.installedVersion = path.Base(bi.Path)
+	a.goVersion = bi.GoVersion
+	a.linked = isGoLink(executablePath)
 
-	res, err := client.Get("https://go.dev/VERSION?m=text")
-	if err != nil {
-		return nil, err
+	if opts.Version != "" {
+		return nil, fmt.Errorf("go toolchains can't be installed at a given version, install golang.org/dl/go<version> instead")
 	}
-	defer func() { _ = res.Body.Close() }()
 
-	body, err := io.ReadAll(res.Body)
+	if opts.Pin != "" {
+		a.targetVersion = opts.Pin
+		a.pinned = true
+		return a, nil
+	}
+
+	releases, err := goReleases(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	a.targetVersion = strings.SplitN(string(body), "\n", 2)[0]
+	if a.linked {
+		a.targetVersion = latestInSeries(releases, opts.Series)
+		if a.targetVersion == "" && opts.Series != "" {
+			return nil, fmt.Errorf("no stable go release in series %s", opts.Series)
+		} else if a.targetVersion == "" {
+			return nil, fmt.Errorf("no stable go release found")
+		}
+	} else {
+		a.targetVersion = latestInSeries(releases, toolchainSeries(a.installedVersion))
+		if a.targetVersion == "" {
+			// e.g. a release candidate of an unreleased series
+			a.targetVersion = a.installedVersion
+		}
+	}
+
+	if archive, ok := sdkArchive(releases, a.targetVersion); ok {
+		a.archive = &archive
+	}
 
 	return a, nil
 }
 
-func (b *goToolchain) ModulePath() string       { return "golang.org/dl" }
+func (b *goToolchain) ExecutablePath() string   { return b.executablePath }
+func (b *goToolchain) ModulePath() string       { return update.ToolchainModule }
 func (b *goToolchain) InstallPath() string      { return path.Join(b.ModulePath(), b.targetVersion) }
 func (b *goToolchain) InstalledVersion() string { return b.installedVersion }
 func (b *goToolchain) TargetVersion() string    { return b.targetVersion }
+func (b *goToolchain) Pinned() bool             { return b.pinned }
+func (b *goToolchain) Retracted() string        { return "" }
+func (b *goToolchain) Deprecated() string       { return "" }
+func (b *goToolchain) MajorUpdate() string      { return "" }
 func (b *goToolchain) NeedsUpdate() bool        { return b.TargetVersion() != b.InstalledVersion() }
 
-func (b *goToolchain) Update() error {
-	err := internal.Install(b.InstallPath(), "latest")
+func (b *goToolchain) Update(ctx context.Context) error {
+	toolchainMu.Lock()
+	defer toolchainMu.Unlock()
+
+	goLink := filepath.Join(goBin, "go"+exeSuffix)
+
+	if dryRun {
+		commands := []string{
+			"GOBIN=" + goBin + " " + update.InstallOptions{}.Command(b.InstallPath(), "latest"),
+			b.wrapperPath() + " download",
+		}
+		if b.movesLink() {
+			commands = append(commands, "ln -sf "+b.wrapperPath()+" "+goLink)
+		}
+		printDryRun(commands...)
+		return b.removeSuperseded()
+	}
+
+	// GOBIN is set explicitly, it may differ from the one of the go command,
+	// e.g. with -dir.
+	err := update.Updater{Dir: goBin}.Install(ctx, b.InstallPath(), "latest", update.InstallOptions{})
 	if err != nil {
 		return err
 	}
 
-	err = exec.Command(b.TargetVersion(), "download").Run()
+	err = exec.CommandContext(ctx, b.wrapperPath(), "download").Run()
 	if err != nil {
 		return err
 	}
 
-	err = os.Remove(filepath.Join(goBin, b.installedVersion))
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
+	err = b.verifyDownload(ctx)
+	if err != nil {
 		return err
 	}
 
-	err = os.Remove(filepath.Join(goBin, "go"))
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return err
+	if b.movesLink() {
+		err = os.Remove(goLink)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		err = linkExecutable(b.wrapperPath(), goLink)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = os.Symlink(filepath.Join(goBin, b.targetVersion), filepath.Join(goBin, "go"))
+	return b.removeSuperseded()
+}
+
+// wrapperPath returns the path of the wrapper of the target version in GOBIN.
+// It is run by its path, as GOBIN is not necessarily in PATH.
+func (b *goToolchain) wrapperPath() string {
+	return filepath.Join(goBin, b.targetVersion+exeSuffix)
+}
+
+// removeSuperseded removes the wrapper and SDK of the replaced version, or with
+// keepPrevious those of all older versions of its series. Toolchains the go
+// link points to are kept.
+func (b *goToolchain) removeSuperseded() error {
+	keepPrevious, cleanSDK := false, true
+	if cfg.Toolchain != nil {
+		keepPrevious = cfg.Toolchain.KeepPrevious
+		cleanSDK = cfg.Toolchain.CleanSDK == nil || *cfg.Toolchain.CleanSDK
+	}
+
+	toolchains, err := installedToolchains()
 	if err != nil {
 		return err
 	}
 
+	for _, tc := range toolchains {
+		superseded := tc.version == b.installedVersion
+		if keepPrevious {
+			superseded = toolchainSeries(tc.version) == toolchainSeries(b.installedVersion) &&
+				internal.CompareGoVersions(tc.version, b.installedVersion) < 0
+		}
+		// After moving the go link, it no longer points to this toolchain,
+		// which is not yet the case during a dry run.
+		linked := tc.wrapper != "" && isGoLink(tc.wrapper) && !(tc.wrapper == b.executablePath && b.movesLink())
+		if !superseded || tc.version == b.targetVersion || linked {
+			continue
+		}
+
+		err = removeToolchain(tc, cleanSDK)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// verifyDownload checks the downloaded SDK against the checksum announced by
+// go.dev. Pinned toolchains are resolved without looking up the releases, so
+// this is done here.
+func (b *goToolchain) verifyDownload(ctx context.Context) error {
+	if b.archive == nil {
+		releases, err := goReleases(ctx)
+		if err != nil {
+			return fmt.Errorf("look up checksum: %w", err)
+		}
+		archive, ok := sdkArchive(releases, b.targetVersion)
+		if !ok {
+			return fmt.Errorf("no checksum of the %s SDK for %s/%s found", b.targetVersion, runtime.GOOS, runtime.GOARCH)
+		}
+		b.archive = &archive
+	}
+
+	return verifySDK(b.targetVersion, *b.archive)
+}
+
+// movesLink reports whether an update points the go link to the new version.
+// This is the case if it pointed to this toolchain or does not exist yet,
+// unless disabled in the config.
+func (b *goToolchain) movesLink() bool {
+	if cfg.Toolchain != nil && cfg.Toolchain.Link != nil && !*cfg.Toolchain.Link {
+		return false
+	}
+	if b.linked {
+		return true
+	}
+	_, err := os.Lstat(filepath.Join(goBin, "go"+exeSuffix))
+	return errors.Is(err, os.ErrNotExist)
+}
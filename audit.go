@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sort"
+
+	"moehl.dev/go-update/internal"
+)
+
+// vulnFinding is a single finding reported by govulncheck in its JSON output
+// format.
+type vulnFinding struct {
+	OSV          string `json:"osv"`
+	FixedVersion string `json:"fixed_version"`
+	Trace        []struct {
+		Module  string `json:"module"`
+		Version string `json:"version"`
+	} `json:"trace"`
+}
+
+// vulnerability of a binary in a single module.
+type vulnerability struct {
+	ID           string
+	Module       string
+	Version      string
+	FixedVersion string
+}
+
+// audit runs govulncheck against all binaries, or only those named, and
+// reports the vulnerabilities affecting them together with the action that
+// resolves them. Every go binary is scanned, even if its target version can't
+// be resolved, e.g. because it is built from a private module.
+func audit(ctx context.Context, names []string) error {
+	govulncheck, err := lookupGovulncheck()
+	if err != nil {
+		return err
+	}
+
+	entries, err := readBinDirs()
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		entries, err = selectEntries(entries, names)
+		if err != nil {
+			return err
+		}
+	}
+
+	infos := make([]*debug.BuildInfo, len(entries))
+	parallel(jobs, len(entries), func(i int) {
+		infos[i] = readEntry(entries[i].dir, entries[i], slog.With("path", entries[i].path()))
+	})
+
+	// The target versions only determine the action resolving a vulnerability.
+	artefacts, err := loadArtefacts(ctx, names, false)
+	if err != nil {
+		return err
+	}
+	resolved := make(map[string]Artefact, len(artefacts))
+	for _, a := range artefacts {
+		resolved[a.ExecutablePath()] = a
+	}
+
+	vulns := make([][]vulnerability, len(entries))
+	failed := make([]bool, len(entries))
+	parallel(jobs, len(entries), func(i int) {
+		if infos[i] == nil || ctx.Err() != nil {
+			return
+		}
+		log := slog.With("path", entries[i].path())
+
+		var err error
+		vulns[i], err = scanBinary(ctx, govulncheck, entries[i].path())
+		if err != nil {
+			log.Error("vulnerability scan failed", internal.AttrErr(err))
+			failed[i] = true
+		}
+	})
+
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+
+	table := [][]string{{"Program", "Vulnerability", "Module", "Version", "Fixed Version", "Action"}}
+	for i, info := range infos {
+		for _, v := range vulns[i] {
+			table = append(table, []string{
+				info.Path,
+				v.ID,
+				v.Module,
+				v.Version,
+				v.FixedVersion,
+				vulnAction(info.Main.Path, resolved[entries[i].path()], v),
+			})
+		}
+	}
+
+	if len(table) > 1 {
+		tablePrint(table)
+	}
+
+	switch {
+	case slices.Contains(failed, true):
+		return errFailed
+	case len(table) > 1:
+		return errVulnerable
+	default:
+		fmt.Println("no known vulnerabilities found")
+		return nil
+	}
+}
+
+// lookupGovulncheck looks for govulncheck in PATH and GOBIN.
+func lookupGovulncheck() (string, error) {
+	p, err := exec.LookPath("govulncheck")
+	if err == nil {
+		return p, nil
+	}
+
+	p = filepath.Join(goBin, "govulncheck"+exeSuffix)
+	if _, err := os.Stat(p); err == nil {
+		return p, nil
+	}
+
+	return "", fmt.Errorf("govulncheck not found, install it with " +
+		"'go install golang.org/x/vuln/cmd/govulncheck@latest'")
+}
+
+// scanBinary runs govulncheck in binary mode and returns the vulnerabilities
+// found, one per vulnerability and module.
+func scanBinary(ctx context.Context, govulncheck, path string) ([]vulnerability, error) {
+	outBuf := &bytes.Buffer{}
+	errBuf := &bytes.Buffer{}
+	c := exec.CommandContext(ctx, govulncheck, "-mode", "binary", "-format", "json", path)
+	c.Stdout = outBuf
+	c.Stderr = errBuf
+
+	slog.Debug("executing command", internal.AttrCmd(*c))
+
+	err := c.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, err
+	} else if err != nil && outBuf.Len() == 0 {
+		return nil, fmt.Errorf("%w: %s", err, errBuf.String())
+	}
+
+	seen := make(map[string]bool)
+	var vulns []vulnerability
+
+	// The output is a stream of JSON objects, each containing one message.
+	dec := json.NewDecoder(bufio.NewReader(outBuf))
+	for dec.More() {
+		var msg struct {
+			Finding *vulnFinding `json:"finding"`
+		}
+		err = dec.Decode(&msg)
+		if err != nil {
+			return nil, fmt.Errorf("decode govulncheck output: %w", err)
+		}
+
+		f := msg.Finding
+		if f == nil || len(f.Trace) == 0 {
+			continue
+		}
+
+		// The frames lead from the vulnerable symbol to the entry point, so the
+		// first frame is the vulnerable module. The same vulnerability can be
+		// reported on module, package and symbol level.
+		frame := f.Trace[0]
+		key := f.OSV + " " + frame.Module
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		vulns = append(vulns, vulnerability{
+			ID:           f.OSV,
+			Module:       frame.Module,
+			Version:      frame.Version,
+			FixedVersion: f.FixedVersion,
+		})
+	}
+
+	sort.Slice(vulns, func(i, j int) bool { return vulns[i].ID < vulns[j].ID })
+
+	return vulns, nil
+}
+
+// vulnAction describes how the vulnerability of a binary built from the module
+// can be resolved. The artefact is nil if its target version is unknown.
+func vulnAction(modulePath string, a Artefact, v vulnerability) string {
+	switch {
+	case v.FixedVersion == "":
+		return "no fix available"
+	case v.Module == "stdlib" || v.Module == "toolchain":
+		return "rebuild with a newer go version"
+	case v.Module == modulePath && a == nil:
+		return "update to " + v.FixedVersion + " or later"
+	case v.Module == modulePath && a.NeedsUpdate():
+		return "update"
+	case v.Module == modulePath:
+		return "wait for a fixed release"
+	default:
+		return "update, or wait for a release with the fixed dependency"
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	netrcEnv      = "NETRC"
+	proxyTokenEnv = "GOUPDATE_PROXY_TOKEN"
+)
+
+// netrcLogin holds the credentials of a machine in the netrc file.
+type netrcLogin struct {
+	machine  string
+	login    string
+	password string
+}
+
+// authTransport adds credentials to requests which don't have any, only over
+// https. The token is sent as bearer token to the hosts of the module proxies,
+// the netrc credentials to their machines.
+type authTransport struct {
+	base   http.RoundTripper
+	netrc  []netrcLogin
+	token  string
+	tokens map[string]bool
+}
+
+// newAuthTransport creates the transport with the credentials for the proxies
+// from $GOUPDATE_PROXY_TOKEN and the netrc file.
+func newAuthTransport(base http.RoundTripper, proxyURLs []string) (*authTransport, error) {
+	t := &authTransport{
+		base:   base,
+		token:  os.Getenv(proxyTokenEnv),
+		tokens: make(map[string]bool),
+	}
+
+	for _, p := range proxyURLs {
+		u, err := url.Parse(p)
+		if err == nil && u.Host != "" {
+			t.tokens[u.Hostname()] = true
+		}
+	}
+
+	path, err := netrcPath()
+	if err != nil {
+		return t, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return t, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read netrc: %w", err)
+	}
+	t.netrc = parseNetrc(string(b))
+
+	return t, nil
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	host := req.URL.Hostname()
+	if t.token != "" && t.tokens[host] && req.URL.Scheme == "https" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+t.token)
+		return t.base.RoundTrip(req)
+	}
+
+	for _, l := range t.netrc {
+		if l.machine == host && req.URL.Scheme == "https" {
+			req = req.Clone(req.Context())
+			req.SetBasicAuth(l.login, l.password)
+			break
+		}
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// netrcPath returns the path of the netrc file, like the go command does.
+func netrcPath() (string, error) {
+	if p := os.Getenv(netrcEnv); p != "" {
+		return p, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, netrcName), nil
+}
+
+// parseNetrc parses the machines of a netrc file. Like the go command, the
+// default entry is ignored, as it would send the credentials to every host,
+// including webhooks and vulnerability databases.
+func parseNetrc(data string) []netrcLogin {
+	var machines []*netrcLogin
+	var l *netrcLogin
+	inMacro := false
+
+	for _, line := range strings.Split(data, "\n") {
+		if inMacro {
+			// macros run until the next empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			next := ""
+			if i+1 < len(fields) {
+				next = fields[i+1]
+			}
+
+			switch fields[i] {
+			case "machine":
+				l = &netrcLogin{machine: next}
+				machines = append(machines, l)
+				i++
+			case "default":
+				// credentials following it belong to no machine
+				l = nil
+			case "login":
+				if l != nil {
+					l.login = next
+				}
+				i++
+			case "password":
+				if l != nil {
+					l.password = next
+				}
+				i++
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+
+	logins := make([]netrcLogin, 0, len(machines))
+	for _, m := range machines {
+		logins = append(logins, *m)
+	}
+	return logins
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// backupsDir returns the directory containing the backups of all binaries.
+// The backups of a binary are stored as <backupsDir>/<binary>/<version>.
+func backupsDir() string {
+	return filepath.Join(goBin, stateDir, "backups")
+}
+
+// backupPath returns the path of the backup of the executable at the given
+// version.
+func backupPath(executablePath, version string) string {
+	return filepath.Join(backupsDir(), binaryName(filepath.Base(executablePath)), version)
+}
+
+// backup copies the executable into the backup directory under the given
+// version, replacing an existing backup of the same version.
+func backup(executablePath, version string) error {
+	dst := backupPath(executablePath, version)
+	err := os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = copyFile(executablePath, dst)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("created backup", "path", executablePath, "backup", dst)
+
+	return nil
+}
+
+// latestBackup returns the path of the most recently created backup of the
+// binary.
+func latestBackup(name string) (string, error) {
+	dir := filepath.Join(backupsDir(), binaryName(name))
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("no backups of '%s' found", name)
+	} else if err != nil {
+		return "", err
+	}
+
+	var latest fs.FileInfo
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return "", err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if latest == nil || info.ModTime().After(latest.ModTime()) {
+			latest = info
+		}
+	}
+
+	if latest == nil {
+		return "", fmt.Errorf("no backups of '%s' found", name)
+	}
+
+	return filepath.Join(dir, latest.Name()), nil
+}
+
+// rollback restores the most recent backup of every binary given as argument.
+// The restored backup is removed, so a repeated rollback goes back further.
+func rollback(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageError{fmt.Errorf("rollback requires at least one binary")}
+	}
+
+	for _, name := range args {
+		err := checkBinaryName(binaryName(name))
+		if err != nil {
+			return usageError{err}
+		}
+
+		src, err := latestBackup(name)
+		if err != nil {
+			return err
+		}
+
+		dst := binaryPath(name)
+		if dryRun {
+			printDryRun(fmt.Sprintf("cp %s %s", src, dst))
+			continue
+		}
+
+		err = restoreBackup(src, dst)
+		if err != nil {
+			return fmt.Errorf("restore '%s': %w", name, err)
+		}
+
+		err = os.Remove(src)
+		if err != nil {
+			return fmt.Errorf("remove backup of '%s': %w", name, err)
+		}
+
+		fmt.Printf("restored %s %s, pin it to prevent the next update from replacing it\n",
+			name, filepath.Base(src))
+	}
+
+	return nil
+}
+
+// restoreBackup replaces the binary at dst with the backup at src.
+func restoreBackup(src, dst string) error {
+	// Copy next to the binary first, so it can be replaced atomically even
+	// if it is running.
+	tmp := dst + ".go-update"
+	err := copyFile(src, tmp)
+	if err != nil {
+		return err
+	}
+
+	err = replaceWithStaged(tmp, dst)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// copyFile copies the regular file src to dst including its permissions.
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeErr := out.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	_, err = io.Copy(out, in)
+	return err
+}
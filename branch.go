@@ -0,0 +1,21 @@
+package main
+
+import (
+	"runtime/debug"
+
+	"moehl.dev/go-update/pkg/update"
+)
+
+// trackedBranch returns the branch a binary installed at a pseudo-version
+// tracks: the configured one or the one it has been installed from according
+// to its receipt. If it is empty, the resolver falls back to the default
+// branch.
+func trackedBranch(executablePath string, bi debug.BuildInfo, opts ArtefactOptions) string {
+	if opts.Branch != "" {
+		return opts.Branch
+	}
+	if r := installedReceipt(executablePath, bi); r != nil && update.IsBranchQuery(r.Branch) {
+		return r.Branch
+	}
+	return ""
+}
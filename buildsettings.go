@@ -0,0 +1,15 @@
+package main
+
+import (
+	"moehl.dev/go-update/pkg/update"
+)
+
+// installOptions returns the options to install the target version of the
+// artefact with. Only binaries replay their build settings, version stamps in
+// -ldflags are changed to the target version.
+func installOptions(a Artefact) update.InstallOptions {
+	if b, ok := a.(*binary); ok {
+		return b.opts.WithVersion(b.InstalledVersion(), b.TargetVersion())
+	}
+	return update.InstallOptions{}
+}
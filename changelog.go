@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
+)
+
+// showChangelog prints the release notes of each binary before updating it.
+var showChangelog bool
+
+func runChangelog(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return usageError{fmt.Errorf("changelog requires at least one binary")}
+	}
+
+	artefacts, err := loadArtefacts(ctx, names, false)
+	if err != nil {
+		return err
+	}
+
+	for i, a := range artefacts {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		notes, err := changelog(ctx, a)
+		if err != nil {
+			return fmt.Errorf("changelog of %s: %w", a.ExecutablePath(), err)
+		}
+		fmt.Print(notes)
+	}
+
+	return nil
+}
+
+// printChangelog prints the release notes of the artefact as a single block,
+// as updates run concurrently. Failing to fetch them doesn't stop the update.
+func printChangelog(ctx context.Context, a Artefact, log *slog.Logger) {
+	notes, err := changelog(ctx, a)
+	if err != nil {
+		log.Warn("fetching release notes failed", internal.AttrErr(err))
+		return
+	}
+	_, _ = fmt.Fprint(humanOut(), notes+"\n")
+}
+
+// changelog returns the release notes of the versions after the installed one
+// up to the target version. For modules hosted on GitHub they are taken from
+// the releases of the repository, otherwise the sources of both versions are
+// linked.
+func changelog(ctx context.Context, a Artefact) (string, error) {
+	from, to := a.InstalledVersion(), a.TargetVersion()
+	header := fmt.Sprintf("%s %s -> %s\n", a.InstallPath(), from, to)
+
+	if !a.NeedsUpdate() {
+		return header + "up to date\n", nil
+	}
+	if _, ok := a.(*binary); !ok {
+		return header + "release notes are only available for binaries\n", nil
+	}
+
+	owner, repo, tagPrefix, ok := githubRepo(a.ModulePath())
+	if !ok {
+		return header + proxyLinks(a.ModulePath(), from, to), nil
+	}
+
+	releases, err := githubReleases(ctx, owner, repo)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(header)
+	found := false
+	for _, r := range releases {
+		v, ok := strings.CutPrefix(r.TagName, tagPrefix)
+		if !ok || r.Draft || !semver.IsValid(v) {
+			continue
+		}
+		// Releases of other major versions have a different module path.
+		if semver.Major(v) != semver.Major(to) || !inRange(v, from, to) {
+			continue
+		}
+
+		found = true
+		title := r.TagName
+		if r.Name != "" && r.Name != r.TagName {
+			title += " " + r.Name
+		}
+		fmt.Fprintf(&b, "\n## %s (%s)\n%s\n", title, r.PublishedAt.Format(time.DateOnly), r.HTMLURL)
+		if body := strings.TrimSpace(r.Body); body != "" {
+			fmt.Fprintf(&b, "\n%s\n", body)
+		}
+	}
+
+	if !found {
+		fmt.Fprintf(&b, "no release notes found, compare the versions at https://github.com/%s/%s/compare/%s...%s\n",
+			owner, repo, tagPrefix+from, tagPrefix+to)
+	}
+
+	return b.String(), nil
+}
+
+// inRange reports whether v is newer than from and not newer than to. If from
+// is not a valid version, e.g. of a development build, all versions up to to
+// are in range.
+func inRange(v, from, to string) bool {
+	if semver.Compare(v, to) > 0 {
+		return false
+	}
+	return !semver.IsValid(from) || semver.Compare(v, from) > 0
+}
+
+// proxyLinks links the sources of both versions on the first module proxy
+// queried via HTTP, so they can be compared.
+func proxyLinks(modulePath, from, to string) string {
+	proxies := internal.HTTPProxies(goProxies)
+	if len(proxies) == 0 {
+		return "no release notes found\n"
+	}
+
+	escaped, err := module.EscapePath(modulePath)
+	if err != nil {
+		return "no release notes found\n"
+	}
+
+	base := strings.TrimSuffix(proxies[0].URL, "/") + "/" + escaped + "/@v/"
+	links := "no release notes found, compare the sources of both versions:\n"
+	for _, v := range []string{from, to} {
+		if v == update.DevelVersion {
+			continue
+		}
+		ev, err := module.EscapeVersion(v)
+		if err != nil {
+			continue
+		}
+		links += "  " + base + ev + ".zip\n"
+	}
+	return links
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultKeepBackups is the number of backups of each binary kept by clean if
+// neither -keep-backups nor keepBackups in the config file is set.
+const defaultKeepBackups = 3
+
+// keepBackups is the number of most recent backups of each binary kept by
+// clean, 0 selects the configured or default number.
+var keepBackups int
+
+// backupRetention returns the number of backups of each binary kept by clean.
+func backupRetention() int {
+	switch {
+	case keepBackups > 0:
+		return keepBackups
+	case cfg.KeepBackups > 0:
+		return cfg.KeepBackups
+	default:
+		return defaultKeepBackups
+	}
+}
+
+// brokenSymlink reports whether the entry is a symlink whose target doesn't
+// exist, e.g. the go link after the SDK it points to has been deleted, and
+// returns the target.
+func brokenSymlink(entry binEntry) (string, bool) {
+	if entry.Type()&fs.ModeSymlink == 0 {
+		return "", false
+	}
+
+	_, err := os.Stat(entry.path())
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", false
+	}
+
+	target, err := os.Readlink(entry.path())
+	if err != nil {
+		return "", false
+	}
+	return target, true
+}
+
+// brokenSymlinks returns the broken symlinks in the bin directories.
+func brokenSymlinks() ([]binEntry, error) {
+	entries, err := readBinDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	var broken []binEntry
+	for _, entry := range entries {
+		if _, ok := brokenSymlink(entry); ok {
+			broken = append(broken, entry)
+		}
+	}
+	return broken, nil
+}
+
+// cleanable is a file or directory removed by clean.
+type cleanable struct {
+	path string
+	// reason is printed after removing it.
+	reason string
+}
+
+// runClean removes broken symlinks from the bin directories and the files
+// go-update doesn't need anymore: backups beyond the retention, receipts of
+// binaries which don't exist anymore, an expired version cache and staging
+// directories left behind by interrupted updates.
+func runClean(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return usageError{fmt.Errorf("clean does not accept arguments")}
+	}
+
+	entries, err := readBinDirs()
+	if err != nil {
+		return err
+	}
+
+	var found []cleanable
+	for _, find := range []func([]binEntry) ([]cleanable, error){
+		cleanSymlinks,
+		cleanStaging,
+		cleanBackups,
+		cleanReceipts,
+		cleanVersionCache,
+	} {
+		c, err := find(entries)
+		if err != nil {
+			return err
+		}
+		found = append(found, c...)
+	}
+
+	var reclaimed int64
+	for _, c := range found {
+		size := diskUsage(c.path)
+		if dryRun {
+			printDryRun("rm -rf " + c.path)
+			reclaimed += size
+			continue
+		}
+
+		err = os.RemoveAll(c.path)
+		if err != nil {
+			return fmt.Errorf("remove %s: %w", c.path, err)
+		}
+		reclaimed += size
+		fmt.Printf("removed %s (%s)\n", c.path, c.reason)
+	}
+
+	switch {
+	case len(found) == 0:
+		fmt.Println("nothing to clean")
+	case dryRun:
+		fmt.Printf("would reclaim %s\n", formatSize(reclaimed))
+	default:
+		fmt.Printf("reclaimed %s\n", formatSize(reclaimed))
+	}
+
+	return nil
+}
+
+func cleanSymlinks(entries []binEntry) ([]cleanable, error) {
+	var found []cleanable
+	for _, entry := range entries {
+		if target, ok := brokenSymlink(entry); ok {
+			found = append(found, cleanable{entry.path(), "broken symlink to " + target})
+		}
+	}
+	return found, nil
+}
+
+// cleanStaging finds the staging directories and temporary files left behind
+// in the bin directories by interrupted runs. As clean holds the lock, no
+// other run is using them.
+func cleanStaging(entries []binEntry) ([]cleanable, error) {
+	var found []cleanable
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, stateDir+"-") || (strings.HasSuffix(name, stateDir) && name != stateDir) {
+			found = append(found, cleanable{entry.path(), "left behind by an interrupted run"})
+		}
+	}
+	return found, nil
+}
+
+// cleanBackups finds the backups of each binary except the most recent ones.
+func cleanBackups([]binEntry) ([]cleanable, error) {
+	dirs, err := os.ReadDir(backupsDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	keep := backupRetention()
+	var found []cleanable
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		entries, err := os.ReadDir(filepath.Join(backupsDir(), dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var backups []fs.FileInfo
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				return nil, err
+			}
+			if info.Mode().IsRegular() {
+				backups = append(backups, info)
+			}
+		}
+
+		slices.SortFunc(backups, func(a, b fs.FileInfo) int { return b.ModTime().Compare(a.ModTime()) })
+		for _, b := range backups[min(keep, len(backups)):] {
+			found = append(found, cleanable{
+				filepath.Join(backupsDir(), dir.Name(), b.Name()),
+				fmt.Sprintf("backup beyond the %d most recent ones", keep),
+			})
+		}
+	}
+	return found, nil
+}
+
+// cleanReceipts finds the receipts of binaries which don't exist in any bin
+// directory.
+func cleanReceipts(entries []binEntry) ([]cleanable, error) {
+	receipts, err := os.ReadDir(receiptsDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	installed := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		installed[binaryName(entry.Name())] = true
+	}
+
+	var found []cleanable
+	for _, r := range receipts {
+		name, ok := strings.CutSuffix(r.Name(), ".json")
+		if ok && !installed[name] {
+			found = append(found, cleanable{filepath.Join(receiptsDir(), r.Name()), "receipt of a removed binary"})
+		}
+	}
+	return found, nil
+}
+
+// cleanVersionCache finds the version cache if all of its entries have
+// expired, or caching is disabled, and temporary files of interrupted writes.
+func cleanVersionCache([]binEntry) ([]cleanable, error) {
+	p, err := versionCachePath()
+	if err != nil {
+		return nil, nil
+	}
+
+	matches, err := filepath.Glob(p + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	var found []cleanable
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case m != p:
+			found = append(found, cleanable{m, "left behind by an interrupted run"})
+		case cacheTTL <= 0:
+			found = append(found, cleanable{m, "version cache is disabled"})
+		case time.Since(info.ModTime()) > cacheTTL:
+			found = append(found, cleanable{m, "version cache has expired"})
+		}
+	}
+	return found, nil
+}
+
+// diskUsage returns the size of the file, or of all files in the directory.
+func diskUsage(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
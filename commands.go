@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// command is a subcommand of go-update.
+type command struct {
+	name string
+	// args describes the positional arguments in the usage.
+	args string
+	help string
+	// flags registers the flags specific to the command, may be nil.
+	flags func(fs *flag.FlagSet)
+	run   func(ctx context.Context, args []string) error
+	// locks is set for commands modifying GOBIN, they hold the run lock
+	// unless it is a dry run.
+	locks bool
+}
+
+// listFormat is the output format of the list command.
+var listFormat = "table"
+
+var commands = []*command{
+	{
+		name: "update",
+		args: "[binary[@version]...]",
+		help: "Update all binaries in GOBIN, or only the named ones, optionally to the given version. This is the default command.",
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&interactive, "interactive", interactive, "ask before updating each binary")
+			fs.BoolVar(&prune, "prune", prune, "remove superseded go toolchains after updating")
+			fs.IntVar(&pruneKeep, "keep", pruneKeep, "number of most recent go toolchains of each series kept by -prune")
+			fs.BoolVar(&notify, "notify", notify, "send a desktop notification summarizing the updates")
+			fs.BoolVar(&smokeTest, "smoke-test", smokeTest, "run updated binaries with --version or -h and restore the previous version if it fails")
+			fs.BoolVar(&forceBusy, "force-busy", forceBusy, "replace binaries even if they are running, running processes keep the previous version")
+			fs.BoolVar(&forcePermissions, "force-permissions", forcePermissions, "attempt to replace binaries owned by other users or in directories that are not writable")
+			fs.BoolVar(&showChangelog, "changelog", showChangelog, "print the release notes of each binary before updating it")
+			fs.BoolVar(&explain, "explain", explain, "print the reason for the outcome of each binary to stderr")
+			fs.BoolVar(&fixVulnerable, "fix-vulnerable", fixVulnerable, "only update binaries whose installed version has known vulnerabilities in the OSV database")
+			fs.BoolVar(&checkArchived, "archived", checkArchived, "warn about binaries whose repository on GitHub or GitLab has been archived")
+		},
+		run:   runUpdate,
+		locks: true,
+	},
+	{
+		name: "list",
+		args: "[binary...]",
+		help: "List the installed and latest versions of all binaries in GOBIN, or only the named ones.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&listFormat, "format", listFormat, "output `format`: table, json, markdown or csv")
+			fs.StringVar(&listColumnNames, "columns", listColumnNames, "comma separated `columns` to print: "+listColumnNamesList())
+			fs.StringVar(&listSort, "sort", listSort, "sort by `column`, e.g. name or latest")
+			fs.BoolVar(&listOutdated, "outdated", listOutdated, "only list binaries whose installed version is not the latest one")
+			fs.BoolVar(&explain, "explain", explain, "print the reason for the outcome of each binary to stderr")
+			fs.BoolVar(&checkArchived, "archived", checkArchived, "warn about binaries whose repository on GitHub or GitLab has been archived")
+			fs.BoolVar(&checkVulns, "vulns", checkVulns, "look up known vulnerabilities of the installed versions and the go versions they have been built with in the OSV database")
+		},
+		run: runList,
+	},
+	{
+		name: "check",
+		args: "[binary...]",
+		help: "Check whether binaries are outdated. Exits with code 10 if at least one is.",
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&checkArchived, "archived", checkArchived, "warn about binaries whose repository on GitHub or GitLab has been archived")
+			fs.BoolVar(&checkVulns, "vulns", checkVulns, "look up known vulnerabilities of the installed versions and the go versions they have been built with in the OSV database")
+		},
+		run: runCheck,
+	},
+	{
+		name: "info",
+		args: "binary...",
+		help: "Show the build details of the named binaries.",
+		run:  runInfo,
+	},
+	{
+		name: "why",
+		args: "binary",
+		help: "Explain how the target version of the binary is chosen and whether it would be updated.",
+		run:  runWhy,
+	},
+	{
+		name: "changelog",
+		args: "binary...",
+		help: "Show the release notes of the versions an update of the named binaries would install.",
+		run:  runChangelog,
+	},
+	{
+		name: "tui",
+		args: "[binary...]",
+		help: "Browse binaries in a terminal UI, select and update them.",
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&forceBusy, "force-busy", forceBusy, "replace binaries even if they are running, running processes keep the previous version")
+			fs.BoolVar(&forcePermissions, "force-permissions", forcePermissions, "attempt to replace binaries owned by other users or in directories that are not writable")
+		},
+		run:   runTUI,
+		locks: true,
+	},
+	{
+		name: "daemon",
+		help: "Stay resident and update, or only check, all binaries periodically.",
+		flags: func(fs *flag.FlagSet) {
+			fs.DurationVar(&daemonInterval, "interval", daemonInterval, "time between two runs")
+			fs.BoolVar(&daemonCheck, "check", daemonCheck, "only check for updates instead of installing them")
+			fs.BoolVar(&notify, "notify", notify, "send a desktop notification summarizing the updates of each run")
+			fs.BoolVar(&smokeTest, "smoke-test", smokeTest, "run updated binaries with --version or -h and restore the previous version if it fails")
+			fs.BoolVar(&forceBusy, "force-busy", forceBusy, "replace binaries even if they are running, running processes keep the previous version")
+			fs.BoolVar(&forcePermissions, "force-permissions", forcePermissions, "attempt to replace binaries owned by other users or in directories that are not writable")
+			fs.BoolVar(&checkArchived, "archived", checkArchived, "warn about binaries whose repository on GitHub or GitLab has been archived")
+			fs.StringVar(&daemonListen, "listen", daemonListen, "serve the status on `address` as JSON under /status and as Prometheus metrics under /metrics")
+		},
+		run: runDaemon,
+	},
+	{
+		name: "service",
+		args: "print|install|uninstall",
+		help: "Print, install or uninstall a systemd timer or launchd agent running update periodically.",
+		flags: func(fs *flag.FlagSet) {
+			fs.DurationVar(&serviceInterval, "interval", serviceInterval, "time between two runs")
+			fs.BoolVar(&serviceSystem, "system", serviceSystem, "install for the whole system instead of the current user")
+		},
+		run: runService,
+	},
+	{
+		name: "doctor",
+		help: "Diagnose problems with the environment go-update depends on.",
+		run:  runDoctor,
+	},
+	{
+		name: "prune",
+		help: "Remove superseded go toolchain wrappers and their SDKs, keeping the latest ones of each series.",
+		flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&pruneKeep, "keep", pruneKeep, "number of most recent go toolchains of each series to keep")
+		},
+		run:   runPrune,
+		locks: true,
+	},
+	{
+		name:  "self-update",
+		help:  "Update go-update itself to the latest version.",
+		run:   runSelfUpdate,
+		locks: true,
+	},
+	{
+		name: "rebuild",
+		args: "[binary...]",
+		help: "Reinstall binaries at their installed version with the installed go version.",
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&rebuildStale, "stale", rebuildStale, "only rebuild binaries built with an older go version")
+		},
+		run:   runRebuild,
+		locks: true,
+	},
+	{
+		name:  "pin",
+		args:  "<binary> [version]",
+		help:  "Pin a binary to the given version, or the installed one, so update doesn't replace it. A constraint like ^1.4 limits updates instead.",
+		run:   pin,
+		locks: true,
+	},
+	{
+		name:  "unpin",
+		args:  "binary...",
+		help:  "Remove the pins of the named binaries.",
+		run:   unpin,
+		locks: true,
+	},
+	{
+		name:  "rollback",
+		args:  "binary...",
+		help:  "Restore the most recent backup of the named binaries.",
+		run:   rollback,
+		locks: true,
+	},
+	{
+		name: "downgrade",
+		args: "<binary> [version]",
+		help: "Install the given version of a binary, or the release before the installed one, from a backup or the module proxy.",
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&downgradePin, "pin", downgradePin, "pin the binary to the installed version so update doesn't replace it")
+		},
+		run:   downgrade,
+		locks: true,
+	},
+	{
+		name: "remove",
+		args: "binary...",
+		help: "Remove the named binaries along with their backups and pins.",
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&removeRecord, "record", removeRecord, "record the removal so restore skips the binaries")
+		},
+		run:   remove,
+		locks: true,
+	},
+	{
+		name: "clean",
+		help: "Remove broken symlinks from GOBIN, old backups, receipts of removed binaries, an expired version cache and files left behind by interrupted runs.",
+		flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&keepBackups, "keep-backups", keepBackups, fmt.Sprintf("number of most recent backups of each binary to keep, overrides keepBackups in the config file (default %d)", defaultKeepBackups))
+		},
+		run:   runClean,
+		locks: true,
+	},
+	{
+		name: "freeze",
+		args: "[binary...]",
+		help: "Write a manifest of all binaries, or only the named ones, with their installed versions.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&freezeOutput, "o", freezeOutput, "write the manifest to `file` instead of stdout")
+		},
+		run: runFreeze,
+	},
+	{
+		name: "sbom",
+		args: "[binary...]",
+		help: "Write a software bill of materials of all binaries, or only the named ones, including the modules they have been built from.",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&sbomFormat, "format", sbomFormat, "output `format`: cyclonedx or spdx")
+			fs.StringVar(&sbomOutput, "o", sbomOutput, "write the bill of materials to `file` instead of stdout")
+		},
+		run: runSBOM,
+	},
+	{
+		name:  "restore",
+		args:  "<manifest>",
+		help:  "Install the exact versions of the binaries listed in a manifest written by freeze.",
+		run:   runRestore,
+		locks: true,
+	},
+	{
+		name: "apply",
+		args: "[Gofile]",
+		help: "Install the tools listed in a Gofile which are missing or don't satisfy their version constraint.",
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&autoremove, "autoremove", autoremove, "remove binaries not listed in the Gofile")
+		},
+		run:   runApply,
+		locks: true,
+	},
+	{
+		name: "history",
+		args: "[binary...]",
+		help: "Show the recorded updates of all binaries, or only of the named ones.",
+		flags: func(fs *flag.FlagSet) {
+			fs.DurationVar(&historySince, "since", historySince, "only show updates within the `duration`, e.g. 168h")
+		},
+		run: runHistory,
+	},
+	{
+		name: "audit",
+		args: "[binary...]",
+		help: "Report known vulnerabilities of binaries using govulncheck. Exits with code 11 if any are found.",
+		run:  audit,
+	},
+}
+
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// newFlagSet creates a flag set containing the global flags. They are accepted
+// before and after the command. The current values are used as defaults, so
+// flags given before the command are retained.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	fs.IntVar(&jobs, "jobs", jobs, "number of artefacts to process concurrently")
+	fs.BoolVar(&dryRun, "dry-run", dryRun, "print the commands of an update instead of executing them")
+	fs.BoolVar(&waitLock, "wait", waitLock, "wait for another running go-update to finish instead of exiting")
+	fs.StringVar(&scanDir, "dir", scanDir, "update the binaries in `directory` instead of GOBIN, pins, config and backups are kept there as well")
+	fs.BoolVar(&prerelease, "prerelease", prerelease, "allow prerelease target versions")
+	fs.BoolVar(&allowMajor, "allow-major", allowMajor, "upgrade to newer major versions of modules")
+	fs.BoolVar(&allowDowngrade, "allow-downgrade", allowDowngrade, "install target versions older than the installed ones")
+	fs.BoolVar(&allowReplaced, "allow-replaced", allowReplaced, "update binaries built with replace directives, discarding them")
+	fs.BoolVar(&adoptDevel, "adopt-devel", adoptDevel, "reinstall binaries built from a local checkout at the latest release")
+	fs.Float64Var(&rateLimit, "rate-limit", rateLimit, "maximum module proxy `requests` per second, 0 disables it, overrides $"+rateLimitEnv)
+	fs.DurationVar(&artefactTimeout, "timeout", artefactTimeout, "maximum time to resolve and install a single binary, 0 disables it, overrides $"+timeoutEnv)
+	fs.BoolVar(&events, "events", events, "write lifecycle events as newline delimited JSON to stdout")
+	fs.BoolVar(&quiet, "quiet", quiet, "only print errors and completed updates, same as LOG=quiet")
+	fs.StringVar(&logFile, "log-file", logFile, "also write logs to `path`, e.g. $XDG_STATE_HOME/go-update/log, overrides $"+logFileEnv)
+	fs.TextVar(logLevel, "log-level", logLevel, "minimum `level` of log messages, overrides $LOG")
+
+	return fs
+}
+
+// validateFlags checks the values of the global flags.
+func validateFlags() error {
+	if jobs < 1 {
+		return usageError{fmt.Errorf("-jobs must be at least 1")}
+	}
+	if artefactTimeout < 0 {
+		return usageError{fmt.Errorf("-timeout must not be negative")}
+	}
+	if rateLimit < 0 {
+		return usageError{fmt.Errorf("-rate-limit must not be negative")}
+	}
+	if !slices.Contains([]string{"table", "json", "markdown", "csv"}, listFormat) {
+		return usageError{fmt.Errorf("unknown format '%s'", listFormat)}
+	}
+	err := validateListColumns()
+	if err != nil {
+		return usageError{err}
+	}
+	return nil
+}
+
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// printUsage prints the general usage including all commands and the global
+// flags.
+func printUsage(fs *flag.FlagSet) {
+	fmt.Printf("Usage: %s [flags] [command] [arguments]\n\nCommands:\n", programName())
+
+	table := make([][]string, 0, len(commands))
+	for _, cmd := range commands {
+		table = append(table, []string{"  " + cmd.name + " " + cmd.args, cmd.help})
+	}
+	tablePrint(table)
+
+	fmt.Println("\nFlags:")
+	fs.SetOutput(os.Stdout)
+	fs.PrintDefaults()
+	fs.SetOutput(io.Discard)
+
+	fmt.Printf("\nRun '%s <command> -h' for the flags of a command.\n", programName())
+}
+
+// printCommandUsage prints the usage of a single command including the global
+// flags.
+func printCommandUsage(cmd *command, fs *flag.FlagSet) {
+	fmt.Printf("Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n", programName(), cmd.name, cmd.args, cmd.help)
+	fs.SetOutput(os.Stdout)
+	fs.PrintDefaults()
+	fs.SetOutput(io.Discard)
+}
+
+func runUpdate(ctx context.Context, names []string) error {
+	defer notifyRun()
+
+	names, err := parseVersionArgs(names)
+	if err != nil {
+		return err
+	}
+
+	artefacts, err := loadArtefacts(ctx, names, true)
+	if errors.Is(err, errInterrupted) {
+		_, _ = fmt.Fprintln(humanOut(), "interrupted: "+runSummary())
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if prune {
+		err = pruneToolchains(pruneKeep)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = updateSelf(ctx, artefacts)
+	if err != nil {
+		return err
+	}
+
+	if !quiet || runStats.updated.Load() > 0 || runStats.failed.Load() > 0 {
+		_, _ = fmt.Fprintln(humanOut(), runSummary())
+	}
+
+	if runStats.failed.Load() > 0 {
+		return errFailed
+	}
+
+	return nil
+}
+
+// parseVersionArgs strips the versions from arguments of the form
+// binary@version and records them as the requested versions of the binaries.
+// It returns the names of the binaries.
+func parseVersionArgs(args []string) ([]string, error) {
+	names := make([]string, 0, len(args))
+	for _, arg := range args {
+		name, version, ok := strings.Cut(arg, "@")
+		if ok && (name == "" || version == "") {
+			return nil, usageError{fmt.Errorf("invalid argument '%s', expected binary@version", arg)}
+		}
+		if ok {
+			requestedVersions[binaryName(name)] = version
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// updateSelf updates the running executable if it is among the artefacts,
+// processArtefact defers this to the end of a run.
+func updateSelf(ctx context.Context, artefacts []Artefact) error {
+	for _, a := range artefacts {
+		if !a.NeedsUpdate() || !isSelf(a.ExecutablePath()) {
+			continue
+		}
+
+		if !confirmUpdate(a) {
+			runStats.skipped.Add(1)
+			return nil
+		}
+
+		hooks.OnUpdateStart(a)
+		err := selfUpdate(ctx, a)
+		hooks.OnUpdateDone(a, err)
+		if err != nil {
+			runStats.failed.Add(1)
+			return err
+		}
+		runStats.updated.Add(1)
+		return nil
+	}
+
+	return nil
+}
+
+func runList(ctx context.Context, names []string) error {
+	showMajor = true
+
+	artefacts, err := loadArtefacts(ctx, names, false)
+	if err != nil {
+		return err
+	}
+
+	findDuplicates(artefacts)
+	if listOutdated {
+		artefacts = outdatedArtefacts(artefacts)
+	}
+	if listFormat == "json" || listColumnSelected("released") {
+		lookupReleaseTimes(ctx, artefacts)
+	}
+	// The GitHub API has a low rate limit, so releases are only looked up
+	// if they are printed.
+	if listColumnSelected("release") {
+		lookupGitHubReleases(ctx, artefacts)
+	}
+	sortArtefacts(artefacts)
+
+	switch listFormat {
+	case "json":
+		return printArtefactsJSON(artefacts)
+	case "markdown":
+		printMarkdown(artefactTable(artefacts))
+		return nil
+	case "csv":
+		return printCSV(artefactTable(artefacts))
+	}
+
+	printArtefacts(artefacts)
+	return nil
+}
+
+func runCheck(ctx context.Context, names []string) error {
+	artefacts, err := loadArtefacts(ctx, names, false)
+	if err != nil {
+		return err
+	}
+
+	outdated := outdatedArtefacts(artefacts)
+	if len(outdated) > 0 {
+		printArtefacts(outdated)
+		return errOutdated
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"moehl.dev/go-update/pkg/update"
+)
+
+// config holds the settings from the config file. All settings are optional,
+// the zero value represents the default behavior.
+type config struct {
+	// Prerelease allows prerelease versions as target versions of all
+	// binaries.
+	Prerelease bool `json:"prerelease"`
+
+	// CacheTTL is the duration for which module versions are cached, e.g.
+	// "30m". Zero disables the cache.
+	CacheTTL string `json:"cacheTTL"`
+
+	// Cooldown is the minimum age of a release before it is installed, e.g.
+	// "168h" for a week. Newer releases are held back until it has passed.
+	Cooldown string `json:"cooldown"`
+
+	// Go is the go command used to resolve and install binaries, e.g.
+	// "/usr/local/go1.22/bin/go". It defaults to go in PATH.
+	Go string `json:"go"`
+
+	// BinDirs are additional directories containing binaries to update,
+	// besides GOBIN. Updates are installed into the directory the binary has
+	// been found in.
+	BinDirs []string `json:"binDirs"`
+
+	// SmokeTest runs updated binaries with --version, or -h if that fails,
+	// and restores the previous version if neither exits successfully.
+	SmokeTest bool `json:"smokeTest"`
+
+	// KeepBackups is the number of most recent backups of each binary kept
+	// by clean.
+	KeepBackups int `json:"keepBackups"`
+
+	// CheckArchived warns about binaries whose repository on GitHub or
+	// GitLab has been archived.
+	CheckArchived bool `json:"checkArchived"`
+
+	// Notify sends a desktop notification after updates.
+	Notify bool `json:"notify"`
+
+	// Webhook is called at the end of a run with the updates of the run.
+	Webhook *webhookConfig `json:"webhook"`
+
+	// Toolchain contains settings for go toolchains installed via
+	// golang.org/dl.
+	Toolchain *toolchainConfig `json:"toolchain"`
+
+	// Binaries contains settings for individual binaries keyed by the name of
+	// the binary. They take precedence over the global settings.
+	Binaries map[string]binaryConfig `json:"binaries"`
+}
+
+type webhookConfig struct {
+	URL string `json:"url"`
+
+	// Format is the format of the payload, either "json" (the default) or
+	// "slack" for Slack compatible incoming webhooks.
+	Format string `json:"format"`
+}
+
+type toolchainConfig struct {
+	// Series keeps the toolchain the go link points to on the patch releases
+	// of a minor version, e.g. "1.22", instead of updating it to the latest
+	// release. Other toolchains always stay on their own series.
+	Series string `json:"series"`
+
+	// URL replaces https://go.dev/dl/ as source of the go releases, e.g. with
+	// an internal mirror. It must serve the same JSON format.
+	URL string `json:"url"`
+
+	// GoToolchain decides how toolchains are handled if GOTOOLCHAIN selects a
+	// go version: "skip" (the default) leaves them alone, "bump" updates the
+	// version in GOTOOLCHAIN instead of the golang.org/dl wrappers.
+	GoToolchain string `json:"gotoolchain"`
+
+	// Link moves the go link in GOBIN along when the toolchain it points to
+	// is updated. It defaults to true, if disabled the link is never changed.
+	Link *bool `json:"link"`
+
+	// CleanSDK removes the SDK of the replaced version from ~/sdk after an
+	// update, next to its wrapper. It defaults to true.
+	CleanSDK *bool `json:"cleanSDK"`
+
+	// KeepPrevious keeps the wrapper and SDK of the replaced version after an
+	// update as a fallback. Older versions of the series are removed instead.
+	KeepPrevious bool `json:"keepPrevious"`
+}
+
+type binaryConfig struct {
+	// Prerelease allows prerelease versions as target version of the binary.
+	Prerelease *bool `json:"prerelease"`
+
+	// Cooldown overrides the global cooldown for the binary, "0s" disables
+	// it.
+	Cooldown string `json:"cooldown"`
+
+	// Branch is the branch tracked if the binary is installed at a
+	// pseudo-version, e.g. "master". It defaults to the default branch.
+	Branch string `json:"branch"`
+
+	// Version constrains the target version of the binary, e.g. "^1.4",
+	// "~0.12" or "<2.0.0". A pin takes precedence.
+	Version string `json:"version"`
+
+	// AllowReplaced updates the binary even though it has been built with
+	// replace directives, which are discarded by the update.
+	AllowReplaced bool `json:"allowReplaced"`
+
+	// Probe are the arguments the binary is run with by the smoke test
+	// instead of --version and -h, e.g. ["version"].
+	Probe []string `json:"probe"`
+}
+
+// readConfig reads the config file at path. If the path does not exist, the
+// default config is returned.
+func readConfig(path string) (config, error) {
+	var c config
+
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return c, fmt.Errorf("unable to read config file: %w", err)
+	} else if errors.Is(err, os.ErrNotExist) {
+		// no config file
+		return c, nil
+	}
+
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return c, fmt.Errorf("parse config file: %w", err)
+	}
+
+	if c.Webhook != nil && c.Webhook.Format != "" && c.Webhook.Format != "json" && c.Webhook.Format != "slack" {
+		return c, fmt.Errorf("unknown webhook format '%s'", c.Webhook.Format)
+	}
+
+	if c.Toolchain != nil && c.Toolchain.GoToolchain != "" &&
+		c.Toolchain.GoToolchain != toolchainModeSkip && c.Toolchain.GoToolchain != toolchainModeBump {
+		return c, fmt.Errorf("unknown gotoolchain mode '%s'", c.Toolchain.GoToolchain)
+	}
+
+	for name, bc := range c.Binaries {
+		if bc.Version != "" {
+			_, err = update.ParseConstraint(bc.Version)
+			if err != nil {
+				return c, fmt.Errorf("binary %s: %w", name, err)
+			}
+		}
+		if bc.Cooldown != "" {
+			_, err = time.ParseDuration(bc.Cooldown)
+			if err != nil {
+				return c, fmt.Errorf("binary %s: parse cooldown: %w", name, err)
+			}
+		}
+	}
+
+	if c.Toolchain != nil && c.Toolchain.Series != "" && !seriesPattern.MatchString(c.Toolchain.Series) {
+		return c, fmt.Errorf("invalid toolchain series '%s', expected e.g. 1.22", c.Toolchain.Series)
+	}
+
+	return c, nil
+}
+
+// seriesPattern matches a minor version of go like 1.22 or go1.22.
+var seriesPattern = regexp.MustCompile(`^(go)?1\.\d+$`)
+
+// artefactOptions returns the options for the binary with the given name based
+// on the config, the pins and the command line.
+func artefactOptions(name string) ArtefactOptions {
+	opts := ArtefactOptions{
+		Pin:            pins[name],
+		Prerelease:     cfg.Prerelease || prerelease,
+		ProbeMajor:     showMajor,
+		AllowMajor:     allowMajor,
+		AdoptDevel:     adoptDevel,
+		AllowDowngrade: allowDowngrade,
+		AllowReplaced:  allowReplaced,
+		Cooldown:       cooldown,
+	}
+
+	if cfg.Toolchain != nil {
+		opts.Series = cfg.Toolchain.Series
+	}
+
+	if v, ok := requestedVersions[name]; ok {
+		opts.Version = v
+	}
+
+	bc, ok := cfg.Binaries[name]
+	if ok && bc.Prerelease != nil {
+		opts.Prerelease = *bc.Prerelease
+	}
+	if ok && bc.AllowReplaced {
+		opts.AllowReplaced = true
+	}
+	if ok && bc.Branch != "" {
+		opts.Branch = bc.Branch
+	}
+	if ok && bc.Cooldown != "" {
+		// validated by readConfig
+		opts.Cooldown, _ = time.ParseDuration(bc.Cooldown)
+	}
+	if ok && bc.Version != "" && opts.Pin == "" {
+		opts.Constraint = bc.Version
+	}
+	if update.IsConstraint(opts.Pin) {
+		opts.Constraint, opts.Pin = opts.Pin, ""
+	}
+
+	return opts
+}
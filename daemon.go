@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"moehl.dev/go-update/internal"
+)
+
+var (
+	// daemonInterval is the time between two runs of the daemon.
+	daemonInterval = 24 * time.Hour
+
+	// daemonCheck makes the daemon only check for updates instead of
+	// installing them.
+	daemonCheck bool
+
+	// daemonListen is the address the daemon serves its status on, if set.
+	daemonListen string
+)
+
+// daemonStatus describes the state of the daemon and the result of its last
+// run.
+type daemonStatus struct {
+	Mode    string    `json:"mode"`
+	Running bool      `json:"running"`
+	Runs    int       `json:"runs"`
+	LastRun time.Time `json:"lastRun"`
+	NextRun time.Time `json:"nextRun"`
+	// Duration is the duration of the last run in seconds.
+	Duration  float64 `json:"duration"`
+	Artefacts int     `json:"artefacts"`
+	// Outdated is the number of artefacts which were not at their target
+	// version at the start of the last run.
+	Outdated int `json:"outdated"`
+	// Updated and Failed are the number of updates performed and failed in
+	// the last run.
+	Updated int64  `json:"updated"`
+	Failed  int64  `json:"failed"`
+	Error   string `json:"error,omitempty"`
+}
+
+type daemon struct {
+	mu     sync.Mutex
+	status daemonStatus
+	// failedRuns is the number of runs which returned an error.
+	failedRuns int
+}
+
+// runDaemon scans GOBIN periodically and updates the binaries, or only checks
+// them with -check. It runs until ctx is done.
+func runDaemon(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return usageError{fmt.Errorf("daemon does not accept arguments")}
+	}
+	if daemonInterval <= 0 {
+		return usageError{fmt.Errorf("-interval must be positive")}
+	}
+
+	if !daemonCheck && !dryRun {
+		err := checkWritable(goBin)
+		if err != nil {
+			return err
+		}
+	}
+
+	d := &daemon{status: daemonStatus{Mode: "update"}}
+	if daemonCheck {
+		d.status.Mode = "check"
+	}
+
+	if daemonListen != "" {
+		l, err := net.Listen("tcp", daemonListen)
+		if err != nil {
+			return err
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/status", d.serveStatus)
+		mux.HandleFunc("/metrics", d.serveMetrics)
+		go func() {
+			err := http.Serve(l, mux)
+			slog.Error("serving status failed", internal.AttrErr(err))
+		}()
+		slog.Info("serving status", "address", l.Addr().String())
+	}
+
+	for {
+		d.run(ctx)
+		if ctx.Err() != nil {
+			return errInterrupted
+		}
+
+		d.mu.Lock()
+		d.status.NextRun = time.Now().Add(daemonInterval)
+		d.mu.Unlock()
+
+		select {
+		case <-time.After(daemonInterval):
+		case <-ctx.Done():
+			return errInterrupted
+		}
+	}
+}
+
+// run performs a single scan of GOBIN.
+func (d *daemon) run(ctx context.Context) {
+	d.mu.Lock()
+	d.status.Running = true
+	d.mu.Unlock()
+
+	start := time.Now()
+	done, failed := updatesDone.Load(), updatesFailed.Load()
+	artefacts, err := d.scan(ctx)
+	notifyRun()
+
+	outdated := 0
+	for _, a := range artefacts {
+		if a.NeedsUpdate() {
+			outdated++
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.status.Running = false
+	d.status.Runs++
+	d.status.LastRun = start
+	d.status.Duration = time.Since(start).Seconds()
+	d.status.Artefacts = len(artefacts)
+	d.status.Outdated = outdated
+	d.status.Updated = updatesDone.Load() - done
+	d.status.Failed = updatesFailed.Load() - failed
+	d.status.Error = ""
+	if err != nil && !errors.Is(err, errInterrupted) {
+		d.failedRuns++
+		d.status.Error = err.Error()
+		slog.Error("daemon run failed", internal.AttrErr(err))
+	}
+
+	slog.Info("daemon run done",
+		"mode", d.status.Mode,
+		"artefacts", len(artefacts),
+		"outdated", outdated,
+		"duration", time.Since(start))
+}
+
+func (d *daemon) scan(ctx context.Context) ([]Artefact, error) {
+	// The files may have been changed since the last run.
+	err := readGoBinFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	if !daemonCheck && !dryRun {
+		// Manual runs in between are waited for, the daemon has time.
+		release, err := acquireLock(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	artefacts, err := loadArtefacts(ctx, nil, !daemonCheck)
+	if err != nil || daemonCheck {
+		return artefacts, err
+	}
+
+	return artefacts, updateSelf(ctx, artefacts)
+}
+
+func (d *daemon) serveStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	d.mu.Lock()
+	b, err := json.MarshalIndent(d.status, "", "  ")
+	d.mu.Unlock()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(append(b, '\n'))
+}
+
+// serveMetrics exposes the status in the Prometheus text format.
+func (d *daemon) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	d.mu.Lock()
+	s := d.status
+	failedRuns := d.failedRuns
+	d.mu.Unlock()
+
+	lastRun := 0.0
+	if !s.LastRun.IsZero() {
+		lastRun = float64(s.LastRun.UnixNano()) / 1e9
+	}
+
+	b := strings.Builder{}
+	metric := func(name, typ, help string, value any) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, typ, name, value)
+	}
+	metric("goupdate_runs_total", "counter", "Number of completed runs.", s.Runs)
+	metric("goupdate_run_failures_total", "counter", "Number of runs which failed.", failedRuns)
+	metric("goupdate_updates_total", "counter", "Number of updates performed.", updatesDone.Load())
+	metric("goupdate_update_failures_total", "counter", "Number of updates which failed.", updatesFailed.Load())
+	metric("goupdate_artefacts", "gauge", "Number of artefacts scanned in the last run.", s.Artefacts)
+	metric("goupdate_outdated_artefacts", "gauge", "Number of outdated artefacts at the start of the last run.", s.Outdated)
+	metric("goupdate_last_run_timestamp_seconds", "gauge", "Start of the last run as unix timestamp.", lastRun)
+	metric("goupdate_last_run_duration_seconds", "gauge", "Duration of the last run.", s.Duration)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(b.String()))
+}
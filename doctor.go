@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
+)
+
+// minGoCliVersion is the oldest go command supporting `go install pkg@version`.
+const minGoCliVersion = "go1.16"
+
+// finding is the result of a single diagnostic check.
+type finding struct {
+	ok      bool
+	message string
+	// suggestion explains how to resolve a failed check.
+	suggestion string
+}
+
+// runDoctor checks the environment go-update depends on and reports problems
+// together with suggestions how to fix them.
+func runDoctor(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return usageError{fmt.Errorf("doctor does not accept arguments")}
+	}
+
+	var findings []finding
+	findings = append(findings, checkGoBin()...)
+	findings = append(findings, checkGoCli()...)
+	findings = append(findings, checkGoProxies()...)
+	findings = append(findings, checkPath()...)
+	findings = append(findings, checkBuildInfo()...)
+	findings = append(findings, checkDuplicates()...)
+	findings = append(findings, checkSymlinks()...)
+
+	problems := 0
+	for _, f := range findings {
+		if f.ok {
+			fmt.Printf("[ok]   %s\n", f.message)
+			continue
+		}
+
+		problems++
+		fmt.Printf("[fail] %s\n", f.message)
+		if f.suggestion != "" {
+			fmt.Printf("       -> %s\n", f.suggestion)
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("doctor found %d problem(s)", problems)
+	}
+
+	return nil
+}
+
+func checkGoBin() []finding {
+	err := checkWritable(goBin)
+	if err != nil {
+		return []finding{{
+			message:    err.Error(),
+			suggestion: "fix the permissions of GOBIN or point $GOBIN to a writable directory",
+		}}
+	}
+
+	return []finding{{ok: true, message: fmt.Sprintf("GOBIN %s exists and is writable", goBin)}}
+}
+
+func checkGoCli() []finding {
+	out, err := exec.Command(goCli, "env", "GOVERSION").Output()
+	if err != nil {
+		return []finding{{
+			message:    fmt.Sprintf("unable to run %s: %s", goCli, err.Error()),
+			suggestion: "install go from https://go.dev/dl or fix the go installation in PATH",
+		}}
+	}
+
+	version := strings.TrimSpace(string(out))
+	if internal.CompareGoVersions(version, minGoCliVersion) < 0 {
+		return []finding{{
+			message:    fmt.Sprintf("go command %s is %s, at least %s is required", goCli, version, minGoCliVersion),
+			suggestion: "install a recent go version from https://go.dev/dl",
+		}}
+	}
+
+	return []finding{{ok: true, message: fmt.Sprintf("go command %s is %s", goCli, version)}}
+}
+
+func checkGoProxies() []finding {
+	var findings []finding
+	for _, proxy := range internal.HTTPProxies(goProxies) {
+		p := proxy.URL
+
+		// Any response means the proxy is reachable, even a 404.
+		res, err := client.Get(strings.TrimSuffix(p, "/") + "/golang.org/x/mod/@latest")
+		if err != nil {
+			findings = append(findings, finding{
+				message:    fmt.Sprintf("module proxy %s is not reachable: %s", p, err.Error()),
+				suggestion: "check your network and proxy settings or change $GOPROXY",
+			})
+			continue
+		}
+		_ = res.Body.Close()
+
+		findings = append(findings, finding{ok: true, message: fmt.Sprintf("module proxy %s is reachable", p)})
+	}
+	return findings
+}
+
+func checkPath() []finding {
+	onPath := false
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if sameDir(dir, goBin) {
+			onPath = true
+			break
+		}
+	}
+
+	if !onPath {
+		return []finding{{
+			message:    fmt.Sprintf("GOBIN %s is not in PATH", goBin),
+			suggestion: fmt.Sprintf("add %s to PATH in your shell profile", goBin),
+		}}
+	}
+
+	findings := []finding{{ok: true, message: fmt.Sprintf("GOBIN %s is in PATH", goBin)}}
+
+	entries, err := os.ReadDir(goBin)
+	if err != nil {
+		return findings
+	}
+
+	for _, entry := range entries {
+		bi, err := readGoBinary(filepath.Join(goBin, entry.Name()))
+		if bi == nil && err == nil {
+			continue
+		}
+
+		found := shadowedBy(filepath.Join(goBin, entry.Name()))
+		if found == "" {
+			continue
+		}
+
+		findings = append(findings, finding{
+			message: fmt.Sprintf("%s is shadowed by %s", binaryName(entry.Name()), found),
+			suggestion: fmt.Sprintf("remove %s or move %s before %s in PATH",
+				found, goBin, filepath.Dir(found)),
+		})
+	}
+
+	return findings
+}
+
+func checkBuildInfo() []finding {
+	entries, err := os.ReadDir(goBin)
+	if err != nil {
+		return []finding{{message: fmt.Sprintf("unable to read GOBIN: %s", err.Error())}}
+	}
+
+	var findings []finding
+	for _, entry := range entries {
+		path := filepath.Join(goBin, entry.Name())
+		_, err := readGoBinary(path)
+		if err != nil {
+			findings = append(findings, finding{
+				message: fmt.Sprintf("unable to read build info of %s: %s", path, err.Error()),
+				suggestion: fmt.Sprintf("reinstall it with go install, or add '%s' to %s if it is not a go binary",
+					entry.Name(), filepath.Join(goBin, ignorePath)),
+			})
+		}
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, finding{ok: true, message: "build info of all binaries is readable"})
+	}
+
+	return findings
+}
+
+func checkDuplicates() []finding {
+	entries, err := readBinDirs()
+	if err != nil {
+		return []finding{{message: fmt.Sprintf("unable to read GOBIN: %s", err.Error())}}
+	}
+
+	packages := make(map[string]string)
+	for _, entry := range entries {
+		bi, err := readGoBinary(entry.path())
+		if bi == nil || err != nil || bi.Path == "" {
+			continue
+		}
+		packages[entry.path()] = bi.Path
+	}
+
+	var findings []finding
+	for _, group := range duplicateGroups(packages) {
+		findings = append(findings, finding{
+			message:    fmt.Sprintf("%s is installed %d times: %s", packages[group[0]], len(group), strings.Join(group, ", ")),
+			suggestion: "remove the copies you don't need with go-update remove",
+		})
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, finding{ok: true, message: "no package is installed more than once"})
+	}
+
+	return findings
+}
+
+func checkSymlinks() []finding {
+	broken, err := brokenSymlinks()
+	if err != nil {
+		return []finding{{message: fmt.Sprintf("unable to read GOBIN: %s", err.Error())}}
+	}
+
+	var findings []finding
+	for _, entry := range broken {
+		target, _ := brokenSymlink(entry)
+		findings = append(findings, finding{
+			message:    fmt.Sprintf("%s is a broken symlink to %s", entry.path(), target),
+			suggestion: "remove it with go-update clean",
+		})
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, finding{ok: true, message: "no broken symlinks found"})
+	}
+
+	return findings
+}
+
+// shadowedBy returns the executable with the same name found earlier in PATH
+// than the one at executablePath, e.g. a copy installed by a package manager.
+// Running the binary by its name runs that one, so updating it has no visible
+// effect.
+func shadowedBy(executablePath string) string {
+	found, err := exec.LookPath(binaryName(filepath.Base(executablePath)))
+	if err != nil || sameDir(filepath.Dir(found), filepath.Dir(executablePath)) {
+		return ""
+	}
+	return found
+}
+
+// sameDir reports whether a and b refer to the same directory.
+func sameDir(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(ai, bi)
+}
+
+// readGoBinary reads the build info of a file in a bin directory. It returns
+// neither build info nor an error for ignored files and files which are no go
+// binaries, like the go link, shell scripts and programs written in other
+// languages.
+func readGoBinary(path string) (*debug.BuildInfo, error) {
+	if ignore(excludePatterns, includePatterns, filepath.Base(path)) {
+		return nil, nil
+	}
+
+	bi, err := update.Scanner{MinGoVersion: minGoVersion}.Read(path)
+	if update.IsSkip(err) {
+		return nil, nil
+	}
+	return bi, err
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"debug/buildinfo"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"golang.org/x/mod/semver"
+	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
+)
+
+// downgradePin pins a downgraded binary to its new version.
+var downgradePin bool
+
+// downgrade installs the version of the binary given as the second argument,
+// or the release before the installed one. A backup of the version is restored
+// if there is one, otherwise the version is installed from the module proxy.
+func downgrade(ctx context.Context, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageError{fmt.Errorf("downgrade requires a binary and an optional version")}
+	}
+
+	name := binaryName(args[0])
+	err := checkBinaryName(name)
+	if err != nil {
+		return usageError{err}
+	}
+
+	p := binaryPath(name)
+	bi, err := buildinfo.ReadFile(p)
+	if err != nil {
+		return fmt.Errorf("read build info of '%s': %w", name, err)
+	}
+	if bi.Main.Path == update.ToolchainModule {
+		return fmt.Errorf("go toolchains can't be downgraded, install golang.org/dl/go<version> instead")
+	}
+
+	installed := bi.Main.Version
+	target := ""
+	if len(args) == 2 {
+		target, err = internal.Query(ctx, bi.Main.Path, args[1])
+	} else {
+		target, err = previousVersion(ctx, bi, artefactOptions(name).Prerelease)
+	}
+	if err != nil {
+		return err
+	}
+
+	if semver.IsValid(installed) && semver.Compare(target, installed) >= 0 {
+		return fmt.Errorf("version %s of '%s' is not older than the installed version %s", target, name, installed)
+	}
+
+	src := filepath.Join(backupsDir(), name, target)
+	if _, err := os.Stat(src); err == nil {
+		err = downgradeFromBackup(p, installed, target, src)
+	} else {
+		err = downgradeFromProxy(ctx, name, target)
+	}
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if downgradePin {
+		pins[name] = target
+		err = writePins(filepath.Join(goBin, pinsPath), pins)
+		if err != nil {
+			return fmt.Errorf("pin '%s': %w", name, err)
+		}
+		fmt.Printf("downgraded %s from %s to %s and pinned it\n", name, installed, target)
+	} else {
+		fmt.Printf("downgraded %s from %s to %s, pin it to prevent the next update from replacing it\n", name, installed, target)
+	}
+
+	return nil
+}
+
+// previousVersion returns the highest version of the module which is older
+// than the installed one and has not been retracted.
+func previousVersion(ctx context.Context, bi *debug.BuildInfo, prerelease bool) (string, error) {
+	installed := bi.Main.Version
+	if !semver.IsValid(installed) {
+		return "", fmt.Errorf("installed version %s is not a release, give the version to downgrade to", installed)
+	}
+
+	r := update.Resolver{Options: update.Options{Constraint: "<" + installed, Prerelease: prerelease}}
+	res, err := r.Resolve(ctx, bi)
+	if err != nil {
+		return "", err
+	}
+	return res.Target, nil
+}
+
+// downgradeFromBackup replaces the binary at executablePath with the backup of
+// the target version. The installed version is backed up first, the restored
+// backup is kept.
+func downgradeFromBackup(executablePath, installed, target, src string) error {
+	if dryRun {
+		printDryRun(fmt.Sprintf("cp %s %s", src, executablePath))
+		return nil
+	}
+
+	err := backup(executablePath, installed)
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	start := time.Now()
+	err = restoreBackup(src, executablePath)
+	recordUpdate(executablePath, installed, target, start, err)
+	if err != nil {
+		return fmt.Errorf("restore backup of %s: %w", target, err)
+	}
+
+	return nil
+}
+
+// downgradeFromProxy installs the target version of the binary like an update
+// to an explicit version.
+func downgradeFromProxy(ctx context.Context, name, target string) error {
+	requestedVersions[name] = target
+
+	_, err := loadArtefacts(ctx, []string{name}, true)
+	if err != nil {
+		return err
+	}
+
+	if runStats.failed.Load() > 0 {
+		return errFailed
+	} else if runStats.updated.Load() == 0 {
+		return fmt.Errorf("downgrade of '%s' has been skipped, run with -log-level info for details", name)
+	}
+
+	return nil
+}
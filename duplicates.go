@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// duplicatePaths maps the executable paths of binaries to the paths of the
+// other binaries installed from the same package, see findDuplicates.
+var duplicatePaths = make(map[string][]string)
+
+// findDuplicates records the binaries installed from the same package more
+// than once, e.g. a copy kept under another name. Different packages of the
+// same module, like the commands of golang.org/x/tools, are not duplicates.
+func findDuplicates(artefacts []Artefact) {
+	packages := make(map[string]string)
+	for _, a := range artefacts {
+		if _, ok := a.(*binary); ok {
+			packages[a.ExecutablePath()] = a.InstallPath()
+		}
+	}
+
+	for _, group := range duplicateGroups(packages) {
+		for _, p := range group {
+			duplicatePaths[p] = slices.DeleteFunc(slices.Clone(group), func(other string) bool { return other == p })
+		}
+	}
+}
+
+// duplicateGroups groups the paths of binaries by the package they have been
+// installed from and returns the groups with more than one binary.
+func duplicateGroups(packages map[string]string) [][]string {
+	byPackage := make(map[string][]string)
+	for p, pkg := range packages {
+		byPackage[pkg] = append(byPackage[pkg], p)
+	}
+
+	var groups [][]string
+	for _, paths := range byPackage {
+		if len(paths) > 1 {
+			slices.Sort(paths)
+			groups = append(groups, paths)
+		}
+	}
+	slices.SortFunc(groups, func(a, b []string) int { return strings.Compare(a[0], b[0]) })
+	return groups
+}
+
+// duplicatesLabel returns the names of the other binaries installed from the
+// same package as the artefact.
+func duplicatesLabel(a Artefact) string {
+	var names []string
+	for _, p := range duplicatePaths[a.ExecutablePath()] {
+		names = append(names, binaryName(filepath.Base(p)))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"moehl.dev/go-update/pkg/update"
+)
+
+// events writes lifecycle events as newline delimited JSON to stdout. Output
+// meant for humans is written to stderr instead.
+var events bool
+
+var eventsMu sync.Mutex
+
+const (
+	eventScanStart        = "scan-start"
+	eventArtefactFound    = "artefact-found"
+	eventArtefactResolved = "artefact-resolved"
+	eventUpdateStart      = "update-start"
+	eventUpdateDone       = "update-done"
+	eventError            = "error"
+)
+
+type event struct {
+	Time             time.Time `json:"time"`
+	Type             string    `json:"type"`
+	Path             string    `json:"path,omitempty"`
+	ModulePath       string    `json:"modulePath,omitempty"`
+	InstalledVersion string    `json:"installedVersion,omitempty"`
+	TargetVersion    string    `json:"targetVersion,omitempty"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// emit writes the event to stdout, if events are enabled.
+func emit(e event) {
+	if !events {
+		return
+	}
+
+	e.Time = time.Now().UTC()
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+
+	_, _ = os.Stdout.Write(append(b, '\n'))
+}
+
+// hooks report the steps of a run as events. Progress output can subscribe to
+// them as well.
+var hooks = update.Events{
+	OnArtefactFound: func(b update.Binary) {
+		emit(event{Type: eventArtefactFound, Path: b.Path, ModulePath: b.Info.Main.Path, InstalledVersion: b.Info.Main.Version})
+	},
+	OnResolved: func(a Artefact) {
+		emit(artefactEvent(eventArtefactResolved, a))
+	},
+	OnUpdateStart: func(a Artefact) {
+		emit(artefactEvent(eventUpdateStart, a))
+	},
+	OnUpdateDone: func(a Artefact, err error) {
+		if err == nil {
+			emit(artefactEvent(eventUpdateDone, a))
+			return
+		}
+		e := artefactEvent(eventError, a)
+		e.Error = err.Error()
+		emit(e)
+	},
+	OnError: func(path string, err error) {
+		emit(event{Type: eventError, Path: path, Error: err.Error()})
+	},
+}
+
+// artefactEvent returns an event of the given type describing the artefact.
+func artefactEvent(typ string, a Artefact) event {
+	return event{
+		Type:             typ,
+		Path:             a.ExecutablePath(),
+		ModulePath:       a.ModulePath(),
+		InstalledVersion: a.InstalledVersion(),
+		TargetVersion:    a.TargetVersion(),
+	}
+}
+
+// humanOut returns the writer for output meant for humans, which must not mix
+// with events.
+func humanOut() *os.File {
+	if events {
+		return os.Stderr
+	}
+	return os.Stdout
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// explain prints a line per artefact with the reason for what happened to it,
+// like a compact version of why.
+var explain bool
+
+// explainf prints the reason for the outcome of the artefact at executablePath
+// if -explain is set. The lines are written to stderr, so they don't mix with
+// the output of list.
+func explainf(executablePath, format string, args ...any) {
+	if !explain {
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", binaryName(filepath.Base(executablePath)), fmt.Sprintf(format, args...))
+}
+
+// targetReason describes why the target version of the artefact has been
+// chosen.
+func targetReason(a Artefact) string {
+	if a.Pinned() {
+		return "pinned"
+	}
+
+	b, ok := a.(*binary)
+	if !ok {
+		return "latest go release"
+	}
+
+	switch {
+	case b.res.Requested:
+		return "requested"
+	case b.res.Major:
+		return "newer major version, -allow-major set"
+	case b.res.Branch != "":
+		return "latest commit on " + b.res.Branch
+	}
+	if c := artefactOptions(binaryName(filepath.Base(b.executablePath))).Constraint; c != "" {
+		return "latest version satisfying " + c
+	}
+	return "latest version"
+}
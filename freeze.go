@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"debug/buildinfo"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+
+	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
+)
+
+// freezeOutput is the file the manifest is written to by freeze, stdout if
+// empty.
+var freezeOutput string
+
+// manifestEntry is a binary recorded in a manifest.
+type manifestEntry struct {
+	name    string
+	pkg     string
+	version string
+}
+
+// runFreeze writes a manifest of all binaries in GOBIN, or only of those
+// named, with their installed versions.
+func runFreeze(ctx context.Context, names []string) error {
+	entries, err := readDir(goBin)
+	if err != nil {
+		return err
+	}
+
+	if len(names) > 0 {
+		entries, err = selectEntries(entries, names)
+		if err != nil {
+			return err
+		}
+	}
+
+	infos := make([]*debug.BuildInfo, len(entries))
+	parallel(jobs, len(entries), func(i int) {
+		log := slog.With("path", entries[i].path())
+		infos[i] = readEntry(goBin, entries[i], log)
+	})
+
+	var manifest []manifestEntry
+	for i, info := range infos {
+		if info == nil {
+			continue
+		}
+
+		version := installedVersion(info)
+		if version == update.DevelVersion {
+			slog.Warn("skipping development build", "path", filepath.Join(goBin, entries[i].Name()))
+			continue
+		}
+
+		manifest = append(manifest, manifestEntry{
+			name:    binaryName(entries[i].Name()),
+			pkg:     info.Path,
+			version: version,
+		})
+	}
+
+	b := strings.Builder{}
+	b.WriteString("# Generated by go-update, see `go-update freeze` and `go-update restore`.\n")
+	for _, e := range manifest {
+		b.WriteString(e.name + " " + e.pkg + " " + e.version + "\n")
+	}
+
+	if freezeOutput != "" {
+		return os.WriteFile(freezeOutput, []byte(b.String()), 0644)
+	}
+
+	_, err = os.Stdout.WriteString(b.String())
+	return err
+}
+
+// readManifest reads a manifest written by freeze. Every line consists of the
+// name of a binary, its package and the version. If path is "-", the manifest
+// is read from stdin.
+func readManifest(path string) ([]manifestEntry, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	var manifest []manifestEntry
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if len(l) == 0 || l[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(l)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid manifest entry '%s': expected '<binary> <package> <version>'", l)
+		}
+		// manifests are written on other machines, the name must not lead
+		// outside of GOBIN
+		if err := checkBinaryName(fields[0]); err != nil {
+			return nil, fmt.Errorf("invalid manifest entry '%s': %w", l, err)
+		}
+
+		manifest = append(manifest, manifestEntry{name: fields[0], pkg: fields[1], version: fields[2]})
+	}
+
+	if s.Err() != nil {
+		return nil, fmt.Errorf("read manifest: %w", s.Err())
+	}
+
+	return manifest, nil
+}
+
+// runRestore installs the exact versions of all binaries listed in the
+// manifest. Binaries already installed at their version and binaries removed
+// with `remove -record` are skipped.
+func runRestore(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError{fmt.Errorf("restore requires exactly one manifest, use - for stdin")}
+	}
+
+	manifest, err := readManifest(args[0])
+	if err != nil {
+		return err
+	}
+
+	removed, err := readRemoved()
+	if err != nil {
+		return fmt.Errorf("read removed binaries: %w", err)
+	}
+
+	var pending []manifestEntry
+	for _, e := range manifest {
+		log := slog.With("path", binaryPath(e.name))
+		if removed[e.name] {
+			log.Info("skipping removed binary")
+			continue
+		}
+
+		bi, err := buildinfo.ReadFile(binaryPath(e.name))
+		if err == nil && bi.Path == e.pkg && installedVersion(bi) == e.version {
+			log.Debug("skipping installed binary", "version", e.version)
+			continue
+		}
+
+		pending = append(pending, e)
+	}
+
+	var failed atomic.Int32
+	parallel(jobs, len(pending), func(i int) {
+		e := pending[i]
+		log, flush := artefactLogger(binaryPath(e.name))
+		defer flush()
+
+		ctx, cancel := withArtefactTimeout(ctx)
+		defer cancel()
+
+		err := timeoutError(restoreEntry(ctx, e, update.InstallOptions{}))
+		if err != nil {
+			log.Error("restoring binary failed", internal.AttrErr(err))
+			failed.Add(1)
+			return
+		}
+
+		if !dryRun {
+			log.Info("restored binary", "version", e.version)
+		}
+	})
+
+	if failed.Load() > 0 {
+		return fmt.Errorf("restoring %d binaries failed", failed.Load())
+	}
+
+	return nil
+}
+
+// restoreEntry installs a single manifest entry with the given build flags and
+// environment. It is staged first, so the binary can be installed under its
+// recorded name.
+func restoreEntry(ctx context.Context, e manifestEntry, opts update.InstallOptions) error {
+	dst := binaryPath(e.name)
+
+	if dryRun {
+		printDryRun(
+			fmt.Sprintf("GOBIN=<staging> %s", opts.Command(e.pkg, e.version)),
+			fmt.Sprintf("mv <staging>/* %s", dst),
+		)
+		return nil
+	}
+
+	staged, cleanup, err := stageInstall(ctx, goBin, e.pkg, e.version, opts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	err = replaceWithStaged(staged, dst)
+	if err != nil {
+		return err
+	}
+
+	recordInstall(dst, e.pkg, e.version, "", opts)
+
+	return nil
+}
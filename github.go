@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/module"
+	"moehl.dev/go-update/internal"
+)
+
+// githubAPI is the base URL of the GitHub REST API.
+const githubAPI = "https://api.github.com"
+
+// githubToken authenticates requests to the GitHub API, which raises the rate
+// limit from 60 to 5000 requests per hour. It is read from $GITHUB_TOKEN.
+var githubToken string
+
+// githubRelease is a release as returned by the GitHub API.
+type githubRelease struct {
+	TagName     string        `json:"tag_name"`
+	Name        string        `json:"name"`
+	Body        string        `json:"body"`
+	HTMLURL     string        `json:"html_url"`
+	Draft       bool          `json:"draft"`
+	Prerelease  bool          `json:"prerelease"`
+	PublishedAt time.Time     `json:"published_at"`
+	Assets      []githubAsset `json:"assets"`
+}
+
+// githubAsset is a file attached to a release, like a prebuilt binary.
+type githubAsset struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	DownloadURL string `json:"browser_download_url"`
+}
+
+// githubRepo returns the repository of a module hosted on GitHub and the
+// prefix of its version tags, which is the directory of the module in the
+// repository, e.g. "tools/" for github.com/owner/repo/tools/v2.
+func githubRepo(modulePath string) (owner, repo, tagPrefix string, ok bool) {
+	prefix, _, _ := module.SplitPathVersion(modulePath)
+	parts := strings.Split(prefix, "/")
+	if len(parts) < 3 || parts[0] != "github.com" {
+		return "", "", "", false
+	}
+	if len(parts) > 3 {
+		tagPrefix = path.Join(parts[3:]...) + "/"
+	}
+	return parts[1], parts[2], tagPrefix, true
+}
+
+var (
+	githubReleasesMu    sync.Mutex
+	githubReleasesCache = make(map[string][]githubRelease)
+)
+
+// githubReleases returns the most recent releases of the repository, newest
+// first. They are requested once per run, as multiple binaries may be built
+// from the same repository.
+func githubReleases(ctx context.Context, owner, repo string) ([]githubRelease, error) {
+	key := owner + "/" + repo
+
+	githubReleasesMu.Lock()
+	releases, ok := githubReleasesCache[key]
+	githubReleasesMu.Unlock()
+	if ok {
+		return releases, nil
+	}
+
+	u := fmt.Sprintf("%s/repos/%s/%s/releases?per_page=100", githubAPI, url.PathEscape(owner), url.PathEscape(repo))
+	body, err := githubGet(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(body, &releases)
+	if err != nil {
+		return nil, err
+	}
+
+	githubReleasesMu.Lock()
+	githubReleasesCache[key] = releases
+	githubReleasesMu.Unlock()
+
+	return releases, nil
+}
+
+// githubReleaseOf returns the GitHub release of the version of the module. It
+// returns nil if the module is not hosted on GitHub or the version has not
+// been released there.
+func githubReleaseOf(ctx context.Context, modulePath, version string) (*githubRelease, error) {
+	owner, repo, tagPrefix, ok := githubRepo(modulePath)
+	if !ok {
+		return nil, nil
+	}
+
+	releases, err := githubReleases(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, r := range releases {
+		if r.TagName == tagPrefix+version && !r.Draft {
+			return &releases[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// githubReleaseLabel describes the release, e.g. "v1.2.0 (prerelease, 6
+// assets)".
+func githubReleaseLabel(r *githubRelease) string {
+	name := r.Name
+	if name == "" {
+		name = r.TagName
+	}
+
+	var details []string
+	if r.Prerelease {
+		details = append(details, "prerelease")
+	}
+	switch len(r.Assets) {
+	case 0:
+		details = append(details, "no assets")
+	case 1:
+		details = append(details, "1 asset")
+	default:
+		details = append(details, fmt.Sprintf("%d assets", len(r.Assets)))
+	}
+
+	return name + " (" + strings.Join(details, ", ") + ")"
+}
+
+// githubGet requests the GitHub API, transient errors are retried. Once the
+// rate limit is exhausted, requests fail until it is reset.
+func githubGet(ctx context.Context, u string) ([]byte, error) {
+	var body []byte
+	err := internal.Retry(ctx, u, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if githubToken != "" {
+			req.Header.Set("Authorization", "Bearer "+githubToken)
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = res.Body.Close() }()
+
+		if (res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusTooManyRequests) &&
+			res.Header.Get("X-RateLimit-Remaining") == "0" {
+			return githubRateLimitError(res.Header.Get("X-RateLimit-Reset"))
+		} else if res.StatusCode != http.StatusOK {
+			return &internal.StatusError{URL: u, Status: res.Status, Code: res.StatusCode}
+		}
+
+		body, err = io.ReadAll(res.Body)
+		return err
+	})
+	return body, err
+}
+
+// githubRateLimitError describes an exhausted rate limit, reset is the time it
+// is reset in seconds since the epoch. The error is not retried.
+func githubRateLimitError(reset string) error {
+	msg := "GitHub API rate limit exceeded"
+	if s, err := strconv.ParseInt(reset, 10, 64); err == nil {
+		msg += ", it is reset at " + time.Unix(s, 0).Format(time.TimeOnly)
+	}
+	if githubToken == "" {
+		msg += ", set $" + githubTokenEnv + " to raise it"
+	}
+	return fmt.Errorf("%s", msg)
+}
@@ -1,3 +1,10 @@
 module moehl.dev/go-update
 
 go 1.21.0
+
+require (
+	golang.org/x/mod v0.20.0
+	golang.org/x/term v0.23.0
+)
+
+require golang.org/x/sys v0.23.0 // indirect
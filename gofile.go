@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"debug/buildinfo"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/mod/semver"
+
+	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
+)
+
+// gofilePath is the Gofile used by apply if none is given.
+const gofilePath = "Gofile"
+
+// autoremove removes binaries which are not listed in the Gofile.
+var autoremove bool
+
+var majorSuffix = regexp.MustCompile(`^v[0-9]+$`)
+
+// gofileEntry is a tool listed in a Gofile.
+type gofileEntry struct {
+	pkg string
+	// constraint is either "latest", an exact version, a version prefix like
+	// v1 or v1.2, or a version constraint like ^1.4, see
+	// update.ParseConstraint.
+	constraint string
+	// allowed are the versions satisfying the constraint. Prefixes are
+	// treated like a tilde constraint, e.g. v1.2 allows ~1.2.
+	allowed update.Constraint
+}
+
+// readGofile reads the desired tools from a Gofile. Every line consists of the
+// package to install and an optional version constraint, which defaults to
+// latest. Constraints like >=1.2 <1.5 may span the rest of the line.
+func readGofile(path string) ([]gofileEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []gofileEntry
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if len(l) == 0 || l[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(l)
+		if len(fields) > 2 && !update.IsConstraint(fields[1]) {
+			return nil, fmt.Errorf("invalid Gofile entry '%s': expected '<package> [version]'", l)
+		}
+
+		e := gofileEntry{pkg: fields[0], constraint: "latest"}
+		if len(fields) >= 2 {
+			e.constraint = strings.Join(fields[1:], " ")
+		}
+		switch {
+		case e.constraint == "latest" || e.exact():
+		case semver.IsValid(e.constraint):
+			e.allowed, err = update.ParseConstraint("~" + e.constraint)
+		case update.IsConstraint(e.constraint):
+			e.allowed, err = update.ParseConstraint(e.constraint)
+		default:
+			err = fmt.Errorf("invalid version constraint '%s'", e.constraint)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid Gofile entry of %s: %w", e.pkg, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	if s.Err() != nil {
+		return nil, fmt.Errorf("read Gofile: %w", s.Err())
+	}
+
+	return entries, nil
+}
+
+// exact reports whether the constraint is an exact version, including
+// pseudo-versions.
+func (e gofileEntry) exact() bool {
+	return semver.Canonical(e.constraint) == e.constraint
+}
+
+// matches reports whether the version satisfies the constraint.
+func (e gofileEntry) matches(version string) bool {
+	if e.exact() {
+		return version == e.constraint
+	}
+	return e.allowed.Matches(version)
+}
+
+// resolve returns the newest version of the module satisfying the constraint.
+func (e gofileEntry) resolve(ctx context.Context, module string) (string, error) {
+	if e.exact() {
+		return e.constraint, nil
+	}
+
+	versions, err := internal.ListVersions(ctx, module)
+	if err != nil {
+		return "", err
+	}
+
+	target := update.LatestVersion(e.allowed.Filter(versions), prerelease)
+	if target == "" && e.constraint == "latest" {
+		// There are no suitable tagged versions, let the proxy decide.
+		return internal.Latest(ctx, module)
+	} else if target == "" {
+		return "", fmt.Errorf("no version of %s matches %s", module, e.constraint)
+	}
+
+	return target, nil
+}
+
+// modulePath determines the module providing the package by looking up the
+// versions of each prefix of the package path, longest first.
+func modulePath(ctx context.Context, pkg string) (string, error) {
+	for p := pkg; p != "." && p != "/"; p = path.Dir(p) {
+		_, err := internal.ListVersions(ctx, p)
+		if err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no module provides package %s", pkg)
+}
+
+// defaultBinaryName returns the name go install uses for the binary of the
+// package.
+func defaultBinaryName(pkg string) string {
+	name := path.Base(pkg)
+	if majorSuffix.MatchString(name) && path.Dir(pkg) != "." {
+		name = path.Base(path.Dir(pkg))
+	}
+	return name
+}
+
+// runApply installs the tools listed in the Gofile which are missing or don't
+// satisfy their constraint. With -autoremove, binaries not listed are removed.
+func runApply(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return usageError{fmt.Errorf("apply accepts at most one Gofile")}
+	}
+
+	p := gofilePath
+	if len(args) == 1 {
+		p = args[0]
+	}
+
+	entries, err := readGofile(p)
+	if err != nil {
+		return err
+	}
+
+	dirEntries, err := fs.ReadDir(os.DirFS(goBin), ".")
+	if err != nil {
+		return err
+	}
+
+	infos := make([]*debug.BuildInfo, len(dirEntries))
+	parallel(jobs, len(dirEntries), func(i int) {
+		log := slog.With("path", filepath.Join(goBin, dirEntries[i].Name()))
+		infos[i] = readEntry(goBin, dirEntries[i], log)
+	})
+
+	// installed maps install paths to the names of their binaries.
+	installed := make(map[string][]string)
+	for i, info := range infos {
+		if info != nil {
+			installed[info.Path] = append(installed[info.Path], binaryName(dirEntries[i].Name()))
+		}
+	}
+
+	// targets contains the names of the binaries of each entry.
+	targets := make([][]string, len(entries))
+	// applied contains the names of all binaries listed in the Gofile.
+	applied := make(map[string]bool)
+	for i, e := range entries {
+		targets[i] = installed[e.pkg]
+		if len(targets[i]) == 0 {
+			targets[i] = []string{defaultBinaryName(e.pkg)}
+		}
+		for _, name := range targets[i] {
+			applied[name] = true
+		}
+	}
+
+	var failed atomic.Int32
+	parallel(jobs, len(entries), func(i int) {
+		e := entries[i]
+		for _, name := range targets[i] {
+			if err := checkBinaryName(name); err != nil {
+				slog.Error("applying Gofile entry failed", "package", e.pkg, internal.AttrErr(err))
+				failed.Add(1)
+				continue
+			}
+
+			log, flush := artefactLogger(binaryPath(name))
+			entryCtx, cancel := withArtefactTimeout(ctx)
+			err := timeoutError(applyEntry(entryCtx, e, name, log))
+			cancel()
+			if err != nil {
+				log.Error("applying Gofile entry failed", internal.AttrErr(err))
+				failed.Add(1)
+			}
+			flush()
+		}
+	})
+
+	if autoremove {
+		// The toolchain wrappers are managed along with the go link, they are
+		// not listed in a Gofile.
+		var unlisted []string
+		for i, info := range infos {
+			name := binaryName(dirEntries[i].Name())
+			if info == nil || info.Main.Path == update.ToolchainModule || applied[name] || isSelf(filepath.Join(goBin, dirEntries[i].Name())) {
+				continue
+			}
+			if err := checkBinaryName(name); err != nil {
+				slog.Warn("not removing binary not listed in Gofile", "path", filepath.Join(goBin, dirEntries[i].Name()), internal.AttrErr(err))
+				continue
+			}
+			unlisted = append(unlisted, name)
+		}
+
+		if len(unlisted) > 0 && !dryRun {
+			fmt.Printf("removing binaries not listed in %s: %s, use -dry-run to preview removals\n", p, strings.Join(unlisted, ", "))
+		}
+
+		for _, name := range unlisted {
+			err = removeBinary(name)
+			if err != nil {
+				slog.Error("removing binary failed", internal.AttrErr(err))
+				failed.Add(1)
+			} else if !dryRun {
+				slog.Info("removed binary not listed in Gofile", "path", binaryPath(name))
+			}
+		}
+	}
+
+	if failed.Load() > 0 {
+		return fmt.Errorf("applying %d Gofile entries failed", failed.Load())
+	}
+
+	return nil
+}
+
+// applyEntry installs the newest version satisfying the constraint of the entry
+// under the given name, unless the installed version already satisfies it.
+func applyEntry(ctx context.Context, e gofileEntry, name string, log *slog.Logger) error {
+	installedVersion := ""
+	module := ""
+	// opts replays the build settings of an installed binary, new ones are
+	// installed with the defaults.
+	opts := update.InstallOptions{}
+
+	bi, err := buildinfo.ReadFile(binaryPath(name))
+	if err == nil && bi.Path == e.pkg {
+		installedVersion, module = bi.Main.Version, bi.Main.Path
+		opts = buildSettings(binaryPath(name), *bi)
+	} else {
+		module, err = modulePath(ctx, e.pkg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if installedVersion != "" && e.constraint != "latest" && e.matches(installedVersion) {
+		// Binaries are not moved within their constraint, that is up to update.
+		log.Debug("installed version satisfies constraint", "version", installedVersion)
+		return nil
+	}
+
+	target, err := e.resolve(ctx, module)
+	if err != nil {
+		return err
+	}
+
+	if installedVersion == target {
+		log.Debug("installed version is up to date", "version", installedVersion)
+		return nil
+	}
+
+	if installedVersion != "" && !dryRun {
+		err = backup(binaryPath(name), installedVersion)
+		if err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+	}
+
+	start := time.Now()
+	err = restoreEntry(ctx, manifestEntry{name: name, pkg: e.pkg, version: target}, opts.WithVersion(installedVersion, target))
+	recordUpdate(binaryPath(name), installedVersion, target, start, err)
+	if err != nil {
+		return err
+	}
+
+	if !dryRun {
+		log.Info("installed binary", "version", target, "previous-version", installedVersion)
+		reportUpdate(binaryPath(name), installedVersion, target)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
+)
+
+const (
+	toolchainModeSkip = "skip"
+	toolchainModeBump = "bump"
+)
+
+// toolchainMode returns how toolchains are handled if GOTOOLCHAIN selects a go
+// version.
+func toolchainMode() string {
+	if cfg.Toolchain == nil || cfg.Toolchain.GoToolchain == "" {
+		return toolchainModeSkip
+	}
+	return cfg.Toolchain.GoToolchain
+}
+
+// toolchainSetting is a GOTOOLCHAIN value selecting a go version, like
+// go1.22.3 or go1.22.3+auto, which makes the go command download and run that
+// version itself.
+type toolchainSetting struct {
+	version string
+	// suffix is "+auto", "+path" or empty.
+	suffix string
+	// envFile is the go env file GOTOOLCHAIN is written to, empty if it is
+	// set in the environment of the process or GOENV is off.
+	envFile string
+}
+
+func (s *toolchainSetting) value() string {
+	return s.version + s.suffix
+}
+
+// loadToolchainSetting returns the GOTOOLCHAIN setting if it selects a go
+// version. The go command is only asked if there are toolchain wrappers
+// among infos or bump is enabled for a full scan. Failures are logged.
+func loadToolchainSetting(ctx context.Context, infos []*debug.BuildInfo, fullScan bool) *toolchainSetting {
+	needed := fullScan && toolchainMode() == toolchainModeBump
+	for _, info := range infos {
+		needed = needed || (info != nil && info.Main.Path == update.ToolchainModule)
+	}
+	if !needed {
+		return nil
+	}
+
+	env, err := internal.GoEnv(ctx, goToolchainEnv, "GOENV")
+	if err != nil {
+		slog.Warn("reading GOTOOLCHAIN failed", internal.AttrErr(err))
+		return nil
+	}
+
+	name, suffix, _ := strings.Cut(env[goToolchainEnv], "+")
+	if !toolchainPattern.MatchString(name) {
+		// auto, local or path leave the choice to go.mod files
+		return nil
+	}
+
+	s := &toolchainSetting{version: name}
+	if suffix != "" {
+		s.suffix = "+" + suffix
+	}
+	if _, ok := os.LookupEnv(goToolchainEnv); !ok && env["GOENV"] != "off" {
+		s.envFile = env["GOENV"]
+	}
+
+	return s
+}
+
+// processToolchainSetting resolves the go version GOTOOLCHAIN should select
+// and, if update is set, writes it to the go env file.
+func processToolchainSetting(ctx context.Context, s *toolchainSetting, update bool) Artefact {
+	log := slog.With("path", goToolchainEnv)
+	if s.envFile == "" {
+		log.Warn("GOTOOLCHAIN is set in the environment or GOENV is off, unable to update it")
+		runStats.skipped.Add(1)
+		return nil
+	}
+
+	return processArtefact(ctx, s.envFile, log, update, func(ctx context.Context) (Artefact, error) {
+		return newGoToolchainSetting(ctx, s, artefactOptions(goToolchainEnv))
+	})
+}
+
+// goToolchainSetting updates the go version selected by GOTOOLCHAIN in the go
+// env file. The go command downloads the new version on its next invocation.
+type goToolchainSetting struct {
+	setting       *toolchainSetting
+	targetVersion string
+}
+
+func newGoToolchainSetting(ctx context.Context, s *toolchainSetting, opts ArtefactOptions) (Artefact, error) {
+	a := &goToolchainSetting{setting: s}
+
+	releases, err := goReleases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a.targetVersion = latestInSeries(releases, opts.Series)
+	if a.targetVersion == "" {
+		return nil, fmt.Errorf("no stable go release found")
+	}
+
+	return a, nil
+}
+
+func (t *goToolchainSetting) ExecutablePath() string   { return t.setting.envFile }
+func (t *goToolchainSetting) ModulePath() string       { return "golang.org/toolchain" }
+func (t *goToolchainSetting) InstallPath() string      { return goToolchainEnv }
+func (t *goToolchainSetting) InstalledVersion() string { return t.setting.version }
+func (t *goToolchainSetting) TargetVersion() string    { return t.targetVersion }
+func (t *goToolchainSetting) Pinned() bool             { return false }
+func (t *goToolchainSetting) Retracted() string        { return "" }
+func (t *goToolchainSetting) Deprecated() string       { return "" }
+func (t *goToolchainSetting) MajorUpdate() string      { return "" }
+
+// NeedsUpdate reports whether the target is newer, GOTOOLCHAIN may have been
+// set to a version go.dev does not list yet.
+func (t *goToolchainSetting) NeedsUpdate() bool {
+	return internal.CompareGoVersions(t.targetVersion, t.setting.version) > 0
+}
+
+func (t *goToolchainSetting) Update(ctx context.Context) error {
+	value := t.targetVersion + t.setting.suffix
+
+	if dryRun {
+		printDryRun(internal.SetGoEnvCommand(goToolchainEnv, value))
+		return nil
+	}
+
+	return internal.SetGoEnv(ctx, goToolchainEnv, value)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"moehl.dev/go-update/internal"
+)
+
+// historySince limits the history command to entries newer than the duration.
+var historySince time.Duration
+
+var historyMu sync.Mutex
+
+// updatesDone and updatesFailed count the updates of this process.
+var updatesDone, updatesFailed atomic.Int64
+
+// runUpdates collects the updates recorded since the last call of takeUpdates.
+var runUpdates []historyEntry
+
+// historyEntry is a single update recorded in the history file.
+type historyEntry struct {
+	Binary   string        `json:"binary"`
+	From     string        `json:"from"`
+	To       string        `json:"to"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// historyPath returns the path of the history file. It contains one JSON
+// encoded historyEntry per line.
+func historyPath() string {
+	return filepath.Join(goBin, stateDir, "history.jsonl")
+}
+
+// updateArtefact updates the artefact within the artefact timeout and records
+// the update in the history.
+func updateArtefact(ctx context.Context, a Artefact) error {
+	ctx, cancel := withArtefactTimeout(ctx)
+	defer cancel()
+
+	from, to := a.InstalledVersion(), a.TargetVersion()
+	start := time.Now()
+	hooks.OnUpdateStart(a)
+	err := timeoutError(a.Update(ctx))
+	recordUpdate(a.ExecutablePath(), from, to, start, err)
+	hooks.OnUpdateDone(a, err)
+	return err
+}
+
+// recordUpdate appends an update of the executable that started at start to
+// the history. Failing to record the update is logged but not returned, the
+// update itself is not affected by it. Dry runs are not recorded.
+func recordUpdate(executablePath, from, to string, start time.Time, updateErr error) {
+	if dryRun {
+		return
+	}
+
+	if updateErr != nil {
+		updatesFailed.Add(1)
+	} else {
+		updatesDone.Add(1)
+	}
+
+	entry := historyEntry{
+		Binary:   binaryName(filepath.Base(executablePath)),
+		From:     from,
+		To:       to,
+		Time:     start.UTC(),
+		Duration: time.Since(start),
+	}
+	if updateErr != nil {
+		entry.Error = updateErr.Error()
+	}
+
+	historyMu.Lock()
+	runUpdates = append(runUpdates, entry)
+	historyMu.Unlock()
+
+	err := appendHistory(entry)
+	if err != nil {
+		slog.Warn("recording update in history failed", "path", executablePath, internal.AttrErr(err))
+	}
+}
+
+func appendHistory(entry historyEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	err = os.MkdirAll(filepath.Dir(historyPath()), 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(historyPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(b, '\n'))
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// takeUpdates returns the updates recorded since the last call and resets
+// them.
+func takeUpdates() []historyEntry {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	updates := runUpdates
+	runUpdates = nil
+	return updates
+}
+
+// readHistory returns all recorded updates, oldest first.
+func readHistory() ([]historyEntry, error) {
+	f, err := os.Open(historyPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []historyEntry
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+
+		var entry historyEntry
+		err = json.Unmarshal(s.Bytes(), &entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid history entry '%s': %w", s.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, s.Err()
+}
+
+// runHistory prints the recorded updates of all binaries, or only of those
+// named.
+func runHistory(ctx context.Context, names []string) error {
+	entries, err := readHistory()
+	if err != nil {
+		return fmt.Errorf("read history: %w", err)
+	}
+
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[binaryName(name)] = true
+	}
+
+	table := [][]string{{"Time", "Binary", "From", "To", "Duration", "Result"}}
+	for _, entry := range entries {
+		if len(selected) > 0 && !selected[entry.Binary] {
+			continue
+		}
+		if historySince > 0 && time.Since(entry.Time) > historySince {
+			continue
+		}
+
+		result := "ok"
+		if entry.Error != "" {
+			result = "failed: " + entry.Error
+		}
+
+		table = append(table, []string{
+			entry.Time.Local().Format(time.DateTime),
+			entry.Binary,
+			entry.From,
+			entry.To,
+			entry.Duration.Round(time.Millisecond).String(),
+			result,
+		})
+	}
+
+	if len(table) == 1 {
+		fmt.Println("no updates recorded")
+		return nil
+	}
+
+	tablePrint(table)
+
+	return nil
+}
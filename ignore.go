@@ -42,6 +42,18 @@ func ignoreFile(path string) (exclude, include []string, err error) {
 	return exclude, include, nil
 }
 
+// matchingPatterns returns the patterns matching the name. The ! of include
+// patterns is ignored.
+func matchingPatterns(patterns []string, name string) []string {
+	var matching []string
+	for _, p := range patterns {
+		if m, _ := filepath.Match(strings.TrimPrefix(p, "!"), name); m {
+			matching = append(matching, p)
+		}
+	}
+	return matching
+}
+
 // ignore checks whether the string p should be included. If p matches a pattern
 // from the exclude list, match will return false unless it also matches a
 // pattern from the include list.
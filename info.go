@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"debug/buildinfo"
+	"fmt"
+	"strconv"
+
+	"moehl.dev/go-update/pkg/update"
+)
+
+// runInfo prints the build details of the named binaries.
+func runInfo(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return usageError{fmt.Errorf("info requires at least one binary")}
+	}
+
+	for i, name := range names {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		err := printInfo(ctx, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printInfo(ctx context.Context, name string) error {
+	err := checkBinaryName(binaryName(name))
+	if err != nil {
+		return usageError{err}
+	}
+	p := binaryPath(name)
+
+	bi, err := buildinfo.ReadFile(p)
+	if err != nil {
+		return fmt.Errorf("read build info of '%s': %w", name, err)
+	}
+
+	latest := ""
+	a, err := update.NewArtefact(ctx, p, bi, artefactOptions(binaryName(name)))
+	if err != nil {
+		latest = "unknown: " + err.Error()
+	} else {
+		latest = a.TargetVersion()
+		if a.Pinned() {
+			latest += " (pinned)"
+		}
+	}
+
+	settings := make(map[string]string, len(bi.Settings))
+	for _, s := range bi.Settings {
+		settings[s.Key] = s.Value
+	}
+
+	replaced := 0
+	for _, dep := range bi.Deps {
+		if dep.Replace != nil {
+			replaced++
+		}
+	}
+
+	table := [][]string{
+		{"Binary:", p},
+		{"Module:", bi.Main.Path},
+		{"Install Path:", bi.Path},
+		{"Installed Version:", installedVersion(bi)},
+		{"Latest Version:", latest},
+		{"Go Version:", bi.GoVersion},
+		{"VCS Revision:", settings["vcs.revision"]},
+		{"VCS Time:", settings["vcs.time"]},
+		{"CGO Enabled:", settings["CGO_ENABLED"]},
+		{"Build Tags:", settings["-tags"]},
+		{"Ldflags:", settings["-ldflags"]},
+		{"Dependencies:", fmt.Sprintf("%d (%d replaced)", len(bi.Deps), replaced)},
+	}
+
+	if _, ok := a.(*binary); ok {
+		release := "none"
+		r, err := githubReleaseOf(ctx, a.ModulePath(), a.TargetVersion())
+		if err != nil {
+			release = "unknown: " + err.Error()
+		} else if r != nil {
+			release = githubReleaseLabel(r)
+		}
+		table = append(table, []string{"GitHub Release:", release})
+	}
+
+	if r := installedReceipt(p, *bi); r != nil {
+		table = append(table, []string{"Install Command:", r.Command})
+	}
+
+	if len(bi.Settings) > 0 {
+		table = append(table, []string{"Build Settings:", ""})
+		for _, s := range bi.Settings {
+			table = append(table, []string{"  " + s.Key, strconv.Quote(s.Value)})
+		}
+	}
+
+	tablePrint(table)
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	// interactive asks for confirmation before each update.
+	interactive bool
+
+	// promptMu serializes prompts of concurrently processed artefacts and
+	// guards the remembered answers.
+	promptMu sync.Mutex
+	promptIn = bufio.NewReader(os.Stdin)
+
+	// promptAll and promptQuit remember the answers that apply to all
+	// remaining artefacts.
+	promptAll  bool
+	promptQuit bool
+)
+
+// confirmUpdate asks whether the artefact should be updated, if running in
+// interactive mode. Otherwise, it always returns true.
+func confirmUpdate(a Artefact) bool {
+	if !interactive {
+		return true
+	}
+
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	if promptQuit {
+		return false
+	} else if promptAll {
+		return true
+	}
+
+	for {
+		fmt.Fprintf(humanOut(), "Update %s (%s) from %s to %s? [y,n,a,q] ",
+			filepath.Base(a.ExecutablePath()), a.InstallPath(), a.InstalledVersion(), a.TargetVersion())
+
+		line, err := promptIn.ReadString('\n')
+		if err != nil {
+			// stdin is closed, there won't be any more answers
+			fmt.Fprintln(humanOut())
+			promptQuit = true
+			return false
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true
+		case "n", "no", "":
+			return false
+		case "a", "all":
+			promptAll = true
+			return true
+		case "q", "quit":
+			promptQuit = true
+			return false
+		}
+
+		fmt.Fprintln(humanOut(), "y - update this binary\n"+
+			"n - skip this binary\n"+
+			"a - update this and all remaining binaries\n"+
+			"q - skip this and all remaining binaries")
+	}
+}
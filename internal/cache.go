@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cache holds the versions of all modules listed during this run. If enabled
+// via UseCache, successful results are persisted between runs.
+var cache = &versionCache{entries: make(map[string]versionCacheEntry)}
+
+type versionCache struct {
+	mu      sync.Mutex
+	entries map[string]versionCacheEntry
+
+	// path of the cache file, empty if the cache is not persisted.
+	path string
+	ttl  time.Duration
+}
+
+type versionCacheEntry struct {
+	Versions []string  `json:"versions"`
+	Fetched  time.Time `json:"fetched"`
+
+	// Direct is set if the versions have been listed directly from the
+	// version control system.
+	Direct bool `json:"direct,omitempty"`
+
+	// Info is the module info of the latest version, nil if it has not been
+	// looked up.
+	Info *ModuleInfo `json:"info,omitempty"`
+
+	// Times are the release times of the versions which have been looked up.
+	Times map[string]time.Time `json:"times,omitempty"`
+
+	// err is only kept in memory, failed lookups are retried on the next run.
+	err error
+}
+
+// UseCache loads the version cache from the file at path and persists it there
+// with SaveCache. Entries older than ttl are discarded. A missing file is not
+// an error.
+func UseCache(path string, ttl time.Duration) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.path = path
+	cache.ttl = ttl
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var entries map[string]versionCacheEntry
+	err = json.Unmarshal(b, &entries)
+	if err != nil {
+		// A broken cache is discarded and replaced on the next save.
+		slog.Warn("ignoring invalid version cache", "path", path, AttrErr(err))
+		return nil
+	}
+
+	for module, e := range entries {
+		if time.Since(e.Fetched) < ttl {
+			cache.entries[module] = e
+		}
+	}
+
+	return nil
+}
+
+// SaveCache writes the successfully listed versions to the cache file, if the
+// cache is enabled.
+func SaveCache() error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.path == "" {
+		return nil
+	}
+
+	entries := make(map[string]versionCacheEntry, len(cache.entries))
+	for module, e := range cache.entries {
+		if e.err == nil {
+			entries[module] = e
+		}
+	}
+
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(cache.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	// Write next to the cache and rename, so concurrent runs never see a
+	// partially written file.
+	tmp := fmt.Sprintf("%s.%d", cache.path, os.Getpid())
+	err = os.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, cache.path)
+}
+
+func (c *versionCache) load(module string) ([]string, error, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[module]
+	return e.Versions, e.err, ok
+}
+
+func (c *versionCache) store(module string, versions []string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[module] = versionCacheEntry{Versions: versions, Fetched: time.Now(), err: err}
+}
+
+func (c *versionCache) loadInfo(module string) (ModuleInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[module]
+	if !ok || e.Info == nil {
+		return ModuleInfo{}, false
+	}
+	return *e.Info, true
+}
+
+// storeInfo adds the module info to the entry of the module. The versions of
+// the module are listed before the info, so the entry exists.
+func (c *versionCache) storeInfo(module string, info ModuleInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.entries[module]
+	e.Info = &info
+	c.entries[module] = e
+}
+
+func (c *versionCache) loadTime(module, version string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, ok := c.entries[module].Times[version]
+	return t, ok
+}
+
+// storeTime adds the release time of the version to the entry of the module,
+// if the versions of the module have been listed before.
+func (c *versionCache) storeTime(module, version string, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[module]
+	if !ok {
+		return
+	}
+	if e.Times == nil {
+		e.Times = make(map[string]time.Time)
+	}
+	e.Times[version] = t
+	c.entries[module] = e
+}
+
+// listedDirect reports whether the versions of the module have been listed
+// directly from the version control system.
+func (c *versionCache) listedDirect(module string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.entries[module].Direct
+}
+
+func (c *versionCache) storeDirect(module string, versions []string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[module] = versionCacheEntry{Versions: versions, Fetched: time.Now(), Direct: true, err: err}
+}
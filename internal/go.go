@@ -2,37 +2,141 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"os"
 	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
 )
 
-var goBin string
+// goBin is the path of the go command. It defaults to go in PATH, if it can't
+// be found there, running go commands fails.
+var goBin = "go"
 
 func init() {
-	var err error
-	goBin, err = exec.LookPath("go")
-	if err != nil {
-		panic(fmt.Sprintf("unable to locate go binary: %s", err.Error()))
+	p, err := exec.LookPath("go")
+	if err == nil {
+		goBin = p
 	}
 }
 
-func goCmd(args []string, v any) error {
-	errBuf := &bytes.Buffer{}
-	outBuf := &bytes.Buffer{}
-	c := exec.Cmd{
-		Path:   goBin,
-		Args:   append([]string{"go"}, args...),
-		Stdout: outBuf,
-		Stderr: errBuf,
+// UseGo sets the path of the go command used to resolve and install modules.
+func UseGo(path string) {
+	goBin = path
+}
+
+// ModuleEnv are the go environment variables controlling how the go command
+// downloads, verifies and builds modules. GOTOOLCHAIN is not among them, as
+// go-update changes it with go env -w during a run.
+var ModuleEnv = []string{
+	"GOFLAGS",
+	"GOPROXY",
+	"GONOPROXY",
+	"GOPRIVATE",
+	"GONOSUMDB",
+	"GOSUMDB",
+	"GOINSECURE",
+	"GOVCS",
+	"GOAUTH",
+	"GOPATH",
+	"GOMODCACHE",
+}
+
+// moduleEnv holds the effective values of ModuleEnv, see UseGoEnv.
+var moduleEnv map[string]string
+
+// UseGoEnv sets the effective values of the go environment, as reported by go
+// env, which includes the settings made with go env -w. The values of
+// ModuleEnv are passed to go commands explicitly. If it is not called, go
+// commands inherit the environment of go-update. The values are logged.
+func UseGoEnv(env map[string]string) {
+	moduleEnv = make(map[string]string, len(ModuleEnv))
+	var attrs []any
+	for _, key := range ModuleEnv {
+		if value := env[key]; value != "" {
+			moduleEnv[key] = value
+			attrs = append(attrs, key, value)
+		}
 	}
+	slog.Debug("go command environment", attrs...)
+}
 
-	slog.Debug("executing command", "cmd", c.String())
+// goEnv returns the environment of go commands: the environment of go-update
+// with the effective values of the module related variables, followed by the
+// overrides, e.g. GOBIN=/tmp. Inherited values of these variables are
+// replaced.
+func goEnv(overrides ...string) []string {
+	set := make([]string, 0, len(moduleEnv)+len(overrides))
+	for _, key := range ModuleEnv {
+		if value, ok := moduleEnv[key]; ok {
+			set = append(set, key+"="+value)
+		}
+	}
+	set = append(set, overrides...)
 
-	err := c.Run()
+	replaced := make(map[string]bool, len(set))
+	for _, kv := range set {
+		key, _, _ := strings.Cut(kv, "=")
+		replaced[key] = true
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		if !replaced[key] {
+			env = append(env, kv)
+		}
+	}
+
+	// Later entries take precedence, in case an override sets a module
+	// related variable.
+	return append(env, set...)
+}
+
+func goCommand(args []string) *exec.Cmd {
+	return &exec.Cmd{
+		Path: goBin,
+		Args: append([]string{"go"}, args...),
+		Env:  goEnv(),
+	}
+}
+
+func goCmd(ctx context.Context, args []string, v any) error {
+	return runGo(ctx, goCommand(args), v)
+}
+
+// runGo runs the go command c and decodes its output into v, unless v is nil.
+// The command is repeated if it fails due to a transient network error, and
+// killed once ctx is done.
+func runGo(ctx context.Context, c *exec.Cmd, v any) error {
+	outBuf := &bytes.Buffer{}
+
+	err := Retry(ctx, c.String(), func() error {
+		// a command can only be run once
+		attempt := exec.CommandContext(ctx, c.Path)
+		attempt.Args, attempt.Env, attempt.Dir = c.Args, c.Env, c.Dir
+		errBuf := &bytes.Buffer{}
+		outBuf.Reset()
+		attempt.Stdout = outBuf
+		attempt.Stderr = errBuf
+
+		slog.Debug("executing command", "cmd", attempt.String())
+
+		err := attempt.Run()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil {
+			return fmt.Errorf("%w: %s", err, errBuf.String())
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("%w: %s", err, errBuf.String())
+		return err
 	}
 
 	if v == nil {
@@ -47,10 +151,63 @@ type moduleVersions struct {
 	Versions []string
 }
 
-func ListVersions(module string) ([]string, error) {
+// ListVersions returns the tagged versions of the module sorted by semantic
+// version. The module proxies are asked directly, the go command is only used
+// if that fails. If the module is known but has no versions, e.g. because the
+// proxy has not seen its tags yet, they are listed directly from the version
+// control system, if enabled via UseDirect.
+func ListVersions(ctx context.Context, module string) ([]string, error) {
+	versions, err, ok := cache.load(module)
+	if !ok {
+		versions, err = listVersions(ctx, module)
+		cache.store(module, versions, err)
+	}
+
+	if err == nil && len(versions) == 0 && direct && !cache.listedDirect(module) {
+		slog.Debug("no versions found, listing them from the repository", "module", module)
+
+		directVersions, directErr := listVersionsDirect(ctx, module)
+		if directErr == nil {
+			versions, err = directVersions, nil
+		} else {
+			slog.Debug("listing versions from the repository failed", "module", module, AttrErr(directErr))
+		}
+		cache.storeDirect(module, versions, err)
+	}
+
+	return versions, err
+}
+
+func listVersions(ctx context.Context, module string) ([]string, error) {
+	versions, err := fromProxies(module, func(proxy string) ([]string, error) {
+		return proxyList(ctx, proxy, module)
+	})
+	if err == nil {
+		return versions, nil
+	} else if !useGo(err) {
+		return nil, err
+	}
+	slog.Debug("listing versions via proxy failed, using go command", "module", module, AttrErr(err))
+
+	var v moduleVersions
+
+	err = goCmd(ctx, []string{"list", "-versions", "-json", "-m", module}, &v)
+	if err != nil {
+		return nil, fmt.Errorf("go list: %w", err)
+	}
+
+	return v.Versions, nil
+}
+
+// listVersionsDirect lists the versions of the module from its version control
+// system, bypassing all module proxies.
+func listVersionsDirect(ctx context.Context, module string) ([]string, error) {
+	c := goCommand([]string{"list", "-versions", "-json", "-m", module})
+	c.Env = goEnv("GOPROXY=direct")
+
 	var v moduleVersions
 
-	err := goCmd([]string{"list", "-versions", "-json", "-m", module}, &v)
+	err := runGo(ctx, c, &v)
 	if err != nil {
 		return nil, fmt.Errorf("go list: %w", err)
 	}
@@ -58,6 +215,240 @@ func ListVersions(module string) ([]string, error) {
 	return v.Versions, nil
 }
 
-func Install(pkg string, version string) error {
-	return goCmd([]string{"install", fmt.Sprintf("%s@%s", pkg, version)}, nil)
+// listVersionsChunk is the maximum number of modules passed to a single go list
+// invocation.
+const listVersionsChunk = 100
+
+// PrefetchVersions lists the versions of all modules with as few go list
+// invocations as possible and keeps the results for ListVersions. If module
+// proxies are queried via HTTP, this does nothing, as each request is cheap.
+func PrefetchVersions(ctx context.Context, modules []string) {
+	if len(proxies) > 0 || len(modules) == 0 {
+		return
+	}
+
+	seen := make(map[string]bool, len(modules))
+	missing := modules[:0:0]
+	for _, m := range modules {
+		if _, _, cached := cache.load(m); !seen[m] && !cached {
+			seen[m] = true
+			missing = append(missing, m)
+		}
+	}
+	modules = missing
+
+	for start := 0; start < len(modules); start += listVersionsChunk {
+		end := start + listVersionsChunk
+		if end > len(modules) {
+			end = len(modules)
+		}
+
+		err := listVersionsBatch(ctx, modules[start:end])
+		if err != nil {
+			// ListVersions will try again individually.
+			slog.Debug("prefetching versions failed", AttrErr(err))
+		}
+	}
+}
+
+// listVersionsBatch lists the versions of multiple modules with a single go
+// list invocation and stores them in the cache. Errors of
+// individual modules are stored as well.
+func listVersionsBatch(ctx context.Context, modules []string) error {
+	out := &bytes.Buffer{}
+	errBuf := &bytes.Buffer{}
+	c := exec.CommandContext(ctx, goBin, append([]string{"list", "-versions", "-json", "-e", "-m"}, modules...)...)
+	c.Env = goEnv()
+	c.Stdout = out
+	c.Stderr = errBuf
+
+	slog.Debug("executing command", "cmd", c.String())
+
+	err := c.Run()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, errBuf.String())
+	}
+
+	// go list prints one JSON object per module.
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		var m struct {
+			Path     string
+			Versions []string
+			Error    *struct {
+				Err string
+			}
+		}
+
+		err = dec.Decode(&m)
+		if err != nil {
+			return fmt.Errorf("decode go list output: %w", err)
+		}
+
+		if m.Error != nil {
+			cache.store(m.Path, nil, fmt.Errorf("go list: %s", m.Error.Err))
+		} else {
+			cache.store(m.Path, m.Versions, nil)
+		}
+	}
+
+	return nil
+}
+
+// GoVersion returns the version of the go command, e.g. go1.22.1.
+func GoVersion(ctx context.Context) (string, error) {
+	env, err := GoEnv(ctx, "GOVERSION")
+	return env["GOVERSION"], err
+}
+
+// GoEnv returns the values of the given go environment variables.
+func GoEnv(ctx context.Context, keys ...string) (map[string]string, error) {
+	env := make(map[string]string, len(keys))
+
+	err := goCmd(ctx, append([]string{"env", "-json"}, keys...), &env)
+	if err != nil {
+		return nil, fmt.Errorf("go env: %w", err)
+	}
+
+	return env, nil
+}
+
+func setGoEnvArgs(key, value string) []string {
+	return []string{"env", "-w", key + "=" + value}
+}
+
+// SetGoEnv changes the go environment variable in the go env file.
+func SetGoEnv(ctx context.Context, key, value string) error {
+	return goCmd(ctx, setGoEnvArgs(key, value), nil)
+}
+
+// SetGoEnvCommand returns the command SetGoEnv would execute.
+func SetGoEnvCommand(key, value string) string {
+	return goCommand(setGoEnvArgs(key, value)).String()
+}
+
+// Latest returns the latest version of the module. If there are no tagged
+// versions, this is a pseudo-version of the latest commit.
+func Latest(ctx context.Context, module string) (string, error) {
+	info, err := fromProxies(module, func(proxy string) (VersionInfo, error) {
+		return proxyLatest(ctx, proxy, module)
+	})
+	if err == nil {
+		return info.Version, nil
+	} else if !useGo(err) {
+		return "", err
+	}
+	slog.Debug("resolving latest version via proxy failed, using go command", "module", module, AttrErr(err))
+
+	var m struct {
+		Version string
+	}
+
+	err = goCmd(ctx, []string{"list", "-json", "-m", module + "@latest"}, &m)
+	if err != nil {
+		return "", fmt.Errorf("go list: %w", err)
+	}
+
+	return m.Version, nil
+}
+
+// Query resolves a version query like v1.2, a branch name or a commit hash of
+// the module to a version. Valid semantic versions are looked up as is.
+func Query(ctx context.Context, module, query string) (string, error) {
+	if query == "latest" {
+		return Latest(ctx, module)
+	}
+
+	if semver.IsValid(query) && semver.Canonical(query) == query {
+		info, err := fromProxies(module, func(proxy string) (VersionInfo, error) {
+			return proxyInfo(ctx, proxy, module, query)
+		})
+		if err == nil {
+			return info.Version, nil
+		} else if !useGo(err) {
+			return "", err
+		}
+		slog.Debug("looking up version via proxy failed, using go command", "module", module, "version", query, AttrErr(err))
+	}
+
+	var m struct {
+		Version string
+	}
+
+	err := goCmd(ctx, []string{"list", "-json", "-m", module + "@" + query}, &m)
+	if err != nil {
+		return "", fmt.Errorf("go list: %w", err)
+	}
+
+	return m.Version, nil
+}
+
+// VersionTime returns the time the version of the module has been published,
+// as reported by the module proxy or the version control system.
+func VersionTime(ctx context.Context, module, version string) (time.Time, error) {
+	t, ok := cache.loadTime(module, version)
+	if ok {
+		return t, nil
+	}
+
+	info, err := fromProxies(module, func(proxy string) (VersionInfo, error) {
+		return proxyInfo(ctx, proxy, module, version)
+	})
+	if err != nil && useGo(err) {
+		slog.Debug("looking up version via proxy failed, using go command", "module", module, "version", version, AttrErr(err))
+		err = goCmd(ctx, []string{"list", "-json", "-m", module + "@" + version}, &info)
+		if err != nil {
+			err = fmt.Errorf("go list: %w", err)
+		}
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if info.Time.IsZero() {
+		return time.Time{}, fmt.Errorf("no release time of %s@%s known", module, version)
+	}
+
+	cache.storeTime(module, version, info.Time)
+	return info.Time, nil
+}
+
+// InstallOptions control how go install builds a package.
+type InstallOptions struct {
+	// Flags are build flags like -tags or -ldflags, passed before the
+	// package.
+	Flags []string
+
+	// Env contains additional environment variables like CGO_ENABLED in the
+	// form key=value.
+	Env []string
+}
+
+func installArgs(pkg string, version string, opts InstallOptions) []string {
+	args := append([]string{"install"}, opts.Flags...)
+	return append(args, fmt.Sprintf("%s@%s", pkg, version))
+}
+
+func Install(ctx context.Context, pkg string, version string, opts InstallOptions) error {
+	c := goCommand(installArgs(pkg, version, opts))
+	c.Env = goEnv(opts.Env...)
+	return runGo(ctx, c, nil)
+}
+
+// InstallTo installs the package into the directory gobin instead of the
+// configured GOBIN.
+func InstallTo(ctx context.Context, gobin string, pkg string, version string, opts InstallOptions) error {
+	c := goCommand(installArgs(pkg, version, opts))
+	c.Env = append(goEnv(opts.Env...), "GOBIN="+gobin)
+	return runGo(ctx, c, nil)
+}
+
+// InstallCommand returns the command Install would execute, prefixed with the
+// additional environment variables.
+func InstallCommand(pkg string, version string, opts InstallOptions) string {
+	cmd := goCommand(installArgs(pkg, version, opts)).String()
+	if len(opts.Env) == 0 {
+		return cmd
+	}
+	return strings.Join(opts.Env, " ") + " " + cmd
 }
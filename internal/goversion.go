@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareGoVersions compares two go release versions like go1.21.3 or
+// go1.22rc1. The result is 0 if a == b, -1 if a < b, and +1 if a > b. A
+// missing patch version is treated as zero, and release candidates and betas
+// come before the release. Anything after a space, like the experiments in
+// build info versions, is ignored.
+func CompareGoVersions(a, b string) int {
+	an, apre := parseGoVersion(a)
+	bn, bpre := parseGoVersion(b)
+
+	for i := range an {
+		if an[i] != bn[i] {
+			if an[i] < bn[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case apre == bpre:
+		return 0
+	case apre == "":
+		return 1
+	case bpre == "":
+		return -1
+	case apre < bpre:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// parseGoVersion splits a go version into its major, minor and patch numbers
+// and the prerelease suffix.
+func parseGoVersion(v string) ([3]int, string) {
+	var nums [3]int
+
+	v, _, _ = strings.Cut(v, " ")
+	v = strings.TrimPrefix(v, "go")
+
+	pre := ""
+	if i := strings.IndexAny(v, "abcdefghijklmnopqrstuvwxyz"); i >= 0 {
+		v, pre = v[:i], v[i:]
+	}
+
+	for i, part := range strings.SplitN(v, ".", 3) {
+		nums[i], _ = strconv.Atoi(part)
+	}
+
+	return nums, pre
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// ModuleInfo holds the information module authors publish in the go.mod file
+// of the latest version of a module.
+type ModuleInfo struct {
+	// Retractions are the retracted version ranges.
+	Retractions []Retraction `json:"retractions,omitempty"`
+
+	// Deprecated is the deprecation message of the module, empty if it is
+	// not deprecated.
+	Deprecated string `json:"deprecated,omitempty"`
+}
+
+// Retraction is a retracted range of versions, Low and High are equal for a
+// single version.
+type Retraction struct {
+	Low       string `json:"low"`
+	High      string `json:"high"`
+	Rationale string `json:"rationale,omitempty"`
+}
+
+// Retracted returns the retraction covering the version, if there is one.
+func (i ModuleInfo) Retracted(version string) (Retraction, bool) {
+	for _, r := range i.Retractions {
+		if semver.Compare(r.Low, version) <= 0 && semver.Compare(version, r.High) <= 0 {
+			return r, true
+		}
+	}
+	return Retraction{}, false
+}
+
+// LatestModuleInfo reads the module info from the go.mod file of the latest
+// version of the module, which is where retractions and deprecations are
+// declared.
+func LatestModuleInfo(ctx context.Context, modulePath string) (ModuleInfo, error) {
+	info, ok := cache.loadInfo(modulePath)
+	if ok {
+		return info, nil
+	}
+
+	versions, err := ListVersions(ctx, modulePath)
+	if err != nil {
+		return info, err
+	}
+
+	latest := latestGoModVersion(versions)
+	if latest == "" {
+		// Without tagged versions nothing can be retracted.
+		cache.storeInfo(modulePath, info)
+		return info, nil
+	}
+
+	data, err := goMod(ctx, modulePath, latest)
+	if err != nil {
+		return info, err
+	}
+
+	f, err := modfile.ParseLax("go.mod", data, nil)
+	if err != nil {
+		return info, fmt.Errorf("parse go.mod of %s@%s: %w", modulePath, latest, err)
+	}
+
+	if f.Module != nil {
+		info.Deprecated = f.Module.Deprecated
+	}
+
+	for _, r := range f.Retract {
+		info.Retractions = append(info.Retractions, Retraction{
+			Low:       r.Low,
+			High:      r.High,
+			Rationale: r.Rationale,
+		})
+	}
+
+	cache.storeInfo(modulePath, info)
+
+	return info, nil
+}
+
+// latestGoModVersion returns the version whose go.mod is authoritative for
+// retractions: the highest release, or the highest prerelease if there is no
+// release. Retractions themselves are ignored.
+func latestGoModVersion(versions []string) string {
+	latest, latestPrerelease := "", ""
+	for _, v := range versions {
+		if !semver.IsValid(v) {
+			continue
+		}
+		if semver.Prerelease(v) == "" && (latest == "" || semver.Compare(v, latest) > 0) {
+			latest = v
+		} else if semver.Prerelease(v) != "" && (latestPrerelease == "" || semver.Compare(v, latestPrerelease) > 0) {
+			latestPrerelease = v
+		}
+	}
+
+	if latest == "" {
+		return latestPrerelease
+	}
+	return latest
+}
+
+// goMod returns the go.mod file of the module version.
+func goMod(ctx context.Context, modulePath, version string) ([]byte, error) {
+	data, err := fromProxies(modulePath, func(proxy string) ([]byte, error) {
+		return proxyGoMod(ctx, proxy, modulePath, version)
+	})
+	if err == nil {
+		return data, nil
+	} else if !useGo(err) {
+		return nil, err
+	}
+	slog.Debug("downloading go.mod via proxy failed, using go command", "module", modulePath, AttrErr(err))
+
+	var m struct {
+		GoMod string
+	}
+
+	err = goCmd(ctx, []string{"mod", "download", "-json", modulePath + "@" + version}, &m)
+	if err != nil {
+		return nil, fmt.Errorf("go mod download: %w", err)
+	}
+
+	return os.ReadFile(m.GoMod)
+}
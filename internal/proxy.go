@@ -0,0 +1,196 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"moehl.dev/go-update/pkg/goproxy"
+)
+
+// ErrNotFound is returned by a proxy if it does not know the module or
+// version.
+var ErrNotFound = goproxy.ErrNotFound
+
+// Proxy is an entry of GOPROXY which can be queried via HTTP.
+type Proxy struct {
+	URL string
+
+	// FallbackOnError makes the next proxy be tried after any error, instead
+	// of only if the module or version is not found. It is set for entries
+	// followed by a pipe instead of a comma.
+	FallbackOnError bool
+}
+
+// ParseGoProxy parses the value of GOPROXY. Entries are separated by commas or
+// pipes, after a pipe the next entry is used on any error, after a comma only
+// if the module is not found. The URL of an entry may also be direct or off.
+func ParseGoProxy(s string) []Proxy {
+	var proxies []Proxy
+	for s != "" {
+		i := strings.IndexAny(s, ",|")
+
+		entry, sep := s, byte(0)
+		if i >= 0 {
+			entry, sep, s = s[:i], s[i], s[i+1:]
+		} else {
+			s = ""
+		}
+
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		proxies = append(proxies, Proxy{URL: entry, FallbackOnError: sep == '|'})
+	}
+	return proxies
+}
+
+// DirectAllowed reports whether the proxies allow direct access to version
+// control systems, which is the case if direct is listed before off.
+func DirectAllowed(proxies []Proxy) bool {
+	for _, p := range proxies {
+		switch p.URL {
+		case "direct":
+			return true
+		case "off":
+			return false
+		}
+	}
+	return false
+}
+
+// HTTPProxies returns the leading proxies that can be queried via HTTP. Once
+// the list reaches an entry like direct or off, resolution is left to the go
+// command.
+func HTTPProxies(proxies []Proxy) []Proxy {
+	var leading []Proxy
+	for _, p := range proxies {
+		if !strings.HasPrefix(p.URL, "https://") && !strings.HasPrefix(p.URL, "http://") {
+			break
+		}
+		leading = append(leading, p)
+	}
+	return leading
+}
+
+var (
+	proxyClient = http.DefaultClient
+	proxies     []Proxy
+	// goFallback enables the go command as fallback if no proxy knows a
+	// module.
+	goFallback = true
+	// noProxy is the comma separated list of module path patterns which are
+	// not requested from proxies, see GONOPROXY.
+	noProxy string
+	// direct enables listing versions directly from version control systems
+	// if no versions could be found otherwise.
+	direct bool
+)
+
+// UseProxies configures the module proxies which are queried directly via
+// HTTP, in order. If none are configured, or a request fails, the go command is
+// used instead. If none of them knows a module, the go command is only used if
+// fallback is set.
+func UseProxies(client *http.Client, p []Proxy, fallback bool) {
+	proxyClient = client
+	proxies = p
+	goFallback = fallback
+}
+
+// UseNoProxy configures the module path patterns which are never requested
+// from the proxies, the go command is used for them instead. The patterns
+// have the format of GONOPROXY and GOPRIVATE.
+func UseNoProxy(patterns string) {
+	noProxy = patterns
+}
+
+// UseDirect enables listing versions directly from the version control system
+// of a module if neither the proxies nor the go command found any. This should
+// only be enabled if GOPROXY allows direct access.
+func UseDirect(enabled bool) {
+	direct = enabled
+}
+
+// useGo reports whether the go command should be used after the proxies
+// returned err.
+func useGo(err error) bool {
+	return goFallback || !errors.Is(err, ErrNotFound)
+}
+
+// VersionInfo is the response of the info and latest endpoints of a module
+// proxy.
+type VersionInfo = goproxy.Info
+
+// proxyDo calls fn with a client of the proxy, after waiting for the rate
+// limiter. Transient failures are retried.
+func proxyDo[T any](ctx context.Context, proxy, what string, fn func(c goproxy.Client) (T, error)) (T, error) {
+	c := goproxy.Client{URL: proxy, HTTPClient: proxyClient}
+
+	var res T
+	err := Retry(ctx, proxy+" "+what, func() error {
+		err := limiter.wait(ctx)
+		if err != nil {
+			return err
+		}
+		res, err = fn(c)
+		return err
+	})
+	return res, err
+}
+
+// proxyList lists the versions of the module known to the proxy, sorted by
+// semantic version.
+func proxyList(ctx context.Context, proxy, modulePath string) ([]string, error) {
+	return proxyDo(ctx, proxy, modulePath, func(c goproxy.Client) ([]string, error) {
+		return c.ListVersions(ctx, modulePath)
+	})
+}
+
+// proxyLatest returns the version the proxy considers the latest one.
+func proxyLatest(ctx context.Context, proxy, modulePath string) (VersionInfo, error) {
+	return proxyDo(ctx, proxy, modulePath+"@latest", func(c goproxy.Client) (VersionInfo, error) {
+		return c.Latest(ctx, modulePath)
+	})
+}
+
+// proxyInfo returns the info of the version of the module.
+func proxyInfo(ctx context.Context, proxy, modulePath, version string) (VersionInfo, error) {
+	return proxyDo(ctx, proxy, modulePath+"@"+version, func(c goproxy.Client) (VersionInfo, error) {
+		return c.Info(ctx, modulePath, version)
+	})
+}
+
+// proxyGoMod returns the go.mod file of the version of the module.
+func proxyGoMod(ctx context.Context, proxy, modulePath, version string) ([]byte, error) {
+	return proxyDo(ctx, proxy, modulePath+"@"+version, func(c goproxy.Client) ([]byte, error) {
+		return c.GoMod(ctx, modulePath, version)
+	})
+}
+
+// fromProxies calls fn with the configured proxies until one succeeds. Like the
+// go command, the next proxy is only tried if the module or version is not
+// found, unless the proxy falls back on any error. It fails if no proxy is
+// configured or the module must not be requested from proxies.
+func fromProxies[T any](modulePath string, fn func(proxy string) (T, error)) (T, error) {
+	var res T
+	if module.MatchPrefixPatterns(noProxy, modulePath) {
+		return res, fmt.Errorf("module matches GONOPROXY")
+	}
+
+	err := fmt.Errorf("no module proxy configured")
+
+	for _, p := range proxies {
+		res, err = fn(p.URL)
+		if err == nil {
+			return res, nil
+		} else if !errors.Is(err, ErrNotFound) && !p.FallbackOnError {
+			return res, err
+		}
+	}
+
+	return res, err
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGoProxy(t *testing.T) {
+	tests := []struct {
+		goProxy   string
+		want      []Proxy
+		direct    bool
+		httpCount int
+	}{
+		{
+			goProxy:   "https://proxy.golang.org,direct",
+			want:      []Proxy{{URL: "https://proxy.golang.org"}, {URL: "direct"}},
+			direct:    true,
+			httpCount: 1,
+		},
+		{
+			goProxy: "https://a.example.com|https://b.example.com,off",
+			want: []Proxy{
+				{URL: "https://a.example.com", FallbackOnError: true},
+				{URL: "https://b.example.com"},
+				{URL: "off"},
+			},
+			direct:    false,
+			httpCount: 2,
+		},
+		{
+			goProxy:   "off",
+			want:      []Proxy{{URL: "off"}},
+			direct:    false,
+			httpCount: 0,
+		},
+		{
+			goProxy:   "direct",
+			want:      []Proxy{{URL: "direct"}},
+			direct:    true,
+			httpCount: 0,
+		},
+		{
+			goProxy:   "off,direct",
+			want:      []Proxy{{URL: "off"}, {URL: "direct"}},
+			direct:    false,
+			httpCount: 0,
+		},
+		{
+			// direct ends the proxies queried via HTTP
+			goProxy:   "direct,https://proxy.golang.org",
+			want:      []Proxy{{URL: "direct"}, {URL: "https://proxy.golang.org"}},
+			direct:    true,
+			httpCount: 0,
+		},
+		{
+			goProxy:   " http://localhost:3000 ,, https://proxy.golang.org|",
+			want:      []Proxy{{URL: "http://localhost:3000"}, {URL: "https://proxy.golang.org", FallbackOnError: true}},
+			direct:    false,
+			httpCount: 2,
+		},
+		{
+			goProxy: "",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goProxy, func(t *testing.T) {
+			got := ParseGoProxy(tt.goProxy)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseGoProxy(%q) = %v, want %v", tt.goProxy, got, tt.want)
+			}
+			if direct := DirectAllowed(got); direct != tt.direct {
+				t.Errorf("DirectAllowed(%q) = %t, want %t", tt.goProxy, direct, tt.direct)
+			}
+			if n := len(HTTPProxies(got)); n != tt.httpCount {
+				t.Errorf("len(HTTPProxies(%q)) = %d, want %d", tt.goProxy, n, tt.httpCount)
+			}
+		})
+	}
+}
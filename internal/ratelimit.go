@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket shared by all requests to module proxies. It is
+// disabled if the rate is zero.
+var limiter = &tokenBucket{}
+
+// UseRateLimit limits the requests to module proxies to perSecond on average.
+// Bursts of up to perSecond requests, but at least one, are allowed. Zero
+// disables the limit.
+func UseRateLimit(perSecond float64) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	limiter.rate = perSecond
+	limiter.burst = max(perSecond, 1)
+	limiter.tokens = limiter.burst
+	limiter.last = time.Now()
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// wait takes a token from the bucket and blocks until it is available or ctx
+// is done. Tokens may be taken in advance, which keeps waiting callers in
+// order.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	b.mu.Lock()
+	if b.rate <= 0 {
+		b.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
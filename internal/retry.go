@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"moehl.dev/go-update/pkg/goproxy"
+)
+
+var (
+	// retries is the number of times a failed network operation is retried.
+	retries = 3
+	// retryDelay is the delay before the first retry, it doubles with each
+	// further retry up to maxRetryDelay.
+	retryDelay    = time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+// UseRetries configures how often network operations, i.e. proxy requests and
+// go commands, are retried after a transient failure and the delay before the
+// first retry. Zero retries disable retrying.
+func UseRetries(n int, delay time.Duration) {
+	retries = n
+	retryDelay = delay
+}
+
+// StatusError is returned for unexpected HTTP status codes.
+type StatusError = goproxy.StatusError
+
+// transientMessages are parts of error messages printed by the go command if a
+// request failed in a way that might succeed when repeated.
+var transientMessages = []string{
+	"connection refused",
+	"connection reset",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"temporary failure in name resolution",
+	"429 Too Many Requests",
+	"500 Internal Server Error",
+	"502 Bad Gateway",
+	"503 Service Unavailable",
+	"504 Gateway Timeout",
+}
+
+// Transient reports whether err is caused by a failure which might not occur
+// again, like a timeout or a server error.
+func Transient(err error) bool {
+	if err == nil || errors.Is(err, ErrNotFound) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= http.StatusInternalServerError || statusErr.Code == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	msg := err.Error()
+	for _, m := range transientMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Retry calls fn until it succeeds, fails with an error which is not transient
+// or all retries are used up. The delay between attempts grows exponentially,
+// each one is chosen randomly up to that bound to avoid retrying in lockstep.
+// Once ctx is done, the last error is returned without further attempts.
+func Retry(ctx context.Context, what string, fn func() error) error {
+	err := fn()
+	for attempt := 0; attempt < retries && Transient(err); attempt++ {
+		delay := backoff(attempt)
+		slog.Debug("retrying after transient failure", "op", what, "attempt", attempt+1, "delay", delay, AttrErr(err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+
+		err = fn()
+	}
+	return err
+}
+
+// backoff returns the delay before the given retry, starting at zero.
+func backoff(attempt int) time.Duration {
+	if retryDelay <= 0 {
+		return 0
+	}
+	limit := retryDelay << attempt
+	if limit > maxRetryDelay || limit <= 0 {
+		limit = maxRetryDelay
+	}
+	return time.Duration(rand.Int63n(int64(limit)) + 1)
+}
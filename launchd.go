@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const launchdLabel = "dev.moehl.go-update"
+
+// launchd schedules go-update with a launchd agent, or a daemon for the whole
+// system.
+type launchd struct {
+	// system installs a daemon for the whole system, it is run as the current
+	// user.
+	system bool
+}
+
+func (l launchd) plistPath() (string, error) {
+	if l.system {
+		return filepath.Join("/Library/LaunchDaemons", launchdLabel+".plist"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+func (l launchd) files(exe string, env [][2]string) ([]serviceFile, error) {
+	p, err := l.plistPath()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	logPath := filepath.Join(u.HomeDir, "Library", "Logs", serviceName+".log")
+
+	b := strings.Builder{}
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	plistString(&b, "Label", launchdLabel)
+	if l.system {
+		plistString(&b, "UserName", u.Username)
+	}
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	b.WriteString("\t\t<string>" + xmlEscape(exe) + "</string>\n")
+	b.WriteString("\t\t<string>update</string>\n")
+	b.WriteString("\t</array>\n")
+	b.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+	for _, e := range env {
+		b.WriteString("\t\t<key>" + xmlEscape(e[0]) + "</key>\n")
+		b.WriteString("\t\t<string>" + xmlEscape(e[1]) + "</string>\n")
+	}
+	b.WriteString("\t</dict>\n")
+	b.WriteString(fmt.Sprintf("\t<key>StartInterval</key>\n\t<integer>%d</integer>\n", int(serviceInterval/time.Second)))
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	plistString(&b, "StandardOutPath", logPath)
+	plistString(&b, "StandardErrorPath", logPath)
+	b.WriteString("</dict>\n</plist>\n")
+
+	return []serviceFile{{path: p, content: b.String()}}, nil
+}
+
+// domain returns the launchd domain the service is loaded into.
+func (l launchd) domain() string {
+	if l.system {
+		return "system"
+	}
+	return fmt.Sprintf("gui/%d", os.Getuid())
+}
+
+func (l launchd) install() [][]string {
+	p, _ := l.plistPath()
+	return [][]string{{"launchctl", "bootstrap", l.domain(), p}}
+}
+
+func (l launchd) uninstall() [][]string {
+	p, _ := l.plistPath()
+	return [][]string{{"launchctl", "bootout", l.domain(), p}}
+}
+
+func plistString(b *strings.Builder, key, value string) {
+	b.WriteString("\t<key>" + xmlEscape(key) + "</key>\n")
+	b.WriteString("\t<string>" + xmlEscape(value) + "</string>\n")
+}
+
+func xmlEscape(s string) string {
+	b := strings.Builder{}
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
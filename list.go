@@ -0,0 +1,410 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/semver"
+	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
+)
+
+var (
+	// listColumnNames are the columns printed by list, separated by commas.
+	// If empty, the default columns are printed.
+	listColumnNames string
+
+	// listOutdated only lists the artefacts which need an update.
+	listOutdated bool
+
+	// listSort is the name of the column list sorts the binaries by. If
+	// empty, they are printed in the order they have been found.
+	listSort string
+)
+
+// listColumn is a column of the table of artefacts printed by list and check.
+type listColumn struct {
+	// name identifies the column in -columns and -sort.
+	name   string
+	header string
+	value  func(a Artefact) string
+	// compare orders artefacts by the column, by default their values are
+	// compared as strings.
+	compare func(a, b Artefact) int
+	// optional columns are only printed by default if at least one artefact
+	// has a value.
+	optional bool
+}
+
+var listColumns = []listColumn{
+	{
+		name:   "name",
+		header: "Name",
+		value:  func(a Artefact) string { return binaryName(filepath.Base(a.ExecutablePath())) },
+	},
+	{
+		name:   "path",
+		header: "Path",
+		value:  func(a Artefact) string { return a.ExecutablePath() },
+	},
+	{
+		name:   "program",
+		header: "Program",
+		value:  func(a Artefact) string { return a.InstallPath() },
+	},
+	{
+		name:   "module",
+		header: "Module",
+		value:  func(a Artefact) string { return a.ModulePath() },
+	},
+	{
+		name:    "installed",
+		header:  "Installed Version",
+		value:   installedLabel,
+		compare: func(a, b Artefact) int { return compareVersions(a.InstalledVersion(), b.InstalledVersion()) },
+	},
+	{
+		name:    "latest",
+		header:  "Latest Version",
+		value:   targetLabel,
+		compare: func(a, b Artefact) int { return compareVersions(a.TargetVersion(), b.TargetVersion()) },
+	},
+	{
+		name:   "released",
+		header: "Released",
+		value:  releasedLabel,
+		compare: func(a, b Artefact) int {
+			return releaseTimes[a.ExecutablePath()].Compare(releaseTimes[b.ExecutablePath()])
+		},
+		optional: true,
+	},
+	{
+		name:   "release",
+		header: "GitHub Release",
+		value: func(a Artefact) string {
+			if r := targetReleases[a.ExecutablePath()]; r != nil {
+				return githubReleaseLabel(r)
+			}
+			return ""
+		},
+	},
+	{
+		name:    "size",
+		header:  "Size",
+		value:   func(a Artefact) string { return formatSize(fileSize(a)) },
+		compare: func(a, b Artefact) int { return cmp.Compare(fileSize(a), fileSize(b)) },
+	},
+	{
+		name:    "go",
+		header:  "Go Version",
+		value:   builtWith,
+		compare: func(a, b Artefact) int { return compareVersions(builtWith(a), builtWith(b)) },
+	},
+	{
+		name:     "major",
+		header:   "Major Update",
+		value:    func(a Artefact) string { return a.MajorUpdate() },
+		optional: true,
+	},
+	{
+		name:     "vulns",
+		header:   "Vulnerabilities",
+		value:    vulnsLabel,
+		optional: true,
+	},
+	{
+		name:     "go-vulns",
+		header:   "Go Vulnerabilities",
+		value:    stdlibVulnsLabel,
+		optional: true,
+	},
+	{
+		name:     "duplicates",
+		header:   "Duplicate Of",
+		value:    duplicatesLabel,
+		optional: true,
+	},
+	{
+		name:     "archived",
+		header:   "Archived",
+		value:    archivedLabel,
+		optional: true,
+	},
+	{
+		name:     "deprecated",
+		header:   "Deprecated",
+		value:    func(a Artefact) string { return a.Deprecated() },
+		optional: true,
+	},
+}
+
+// defaultListColumns are printed if no columns are selected, optional ones only
+// if at least one artefact has a value. Size and go version are always known,
+// so they have to be selected.
+var defaultListColumns = []string{"program", "installed", "latest", "released", "major", "vulns", "go-vulns", "duplicates", "archived", "deprecated"}
+
+// lookupListColumn returns the column with the given name.
+func lookupListColumn(name string) (listColumn, bool) {
+	for _, c := range listColumns {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return listColumn{}, false
+}
+
+// listColumnNamesList returns the names of all columns for usage messages.
+func listColumnNamesList() string {
+	names := make([]string, 0, len(listColumns))
+	for _, c := range listColumns {
+		names = append(names, c.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// validateListColumns checks the columns selected with -columns and -sort.
+func validateListColumns() error {
+	if listColumnNames != "" {
+		for _, name := range strings.Split(listColumnNames, ",") {
+			if _, ok := lookupListColumn(strings.TrimSpace(name)); !ok {
+				return fmt.Errorf("unknown column '%s', available are %s", name, listColumnNamesList())
+			}
+		}
+	}
+	if listSort != "" {
+		if _, ok := lookupListColumn(listSort); !ok {
+			return fmt.Errorf("unknown sort column '%s', available are %s", listSort, listColumnNamesList())
+		}
+	}
+	return nil
+}
+
+// listColumnSelected reports whether the column is printed, either because it
+// has been selected or it is a default column.
+func listColumnSelected(name string) bool {
+	if listColumnNames == "" {
+		return slices.Contains(defaultListColumns, name)
+	}
+	for _, n := range strings.Split(listColumnNames, ",") {
+		if strings.TrimSpace(n) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// selectedListColumns returns the columns to print for the artefacts.
+func selectedListColumns(artefacts []Artefact) []listColumn {
+	var columns []listColumn
+
+	if listColumnNames != "" {
+		for _, name := range strings.Split(listColumnNames, ",") {
+			// validated by validateListColumns
+			c, _ := lookupListColumn(strings.TrimSpace(name))
+			columns = append(columns, c)
+		}
+		return columns
+	}
+
+	for _, name := range defaultListColumns {
+		c, _ := lookupListColumn(name)
+		if c.optional && !slices.ContainsFunc(artefacts, func(a Artefact) bool { return c.value(a) != "" }) {
+			continue
+		}
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// artefactTable returns the rows of the artefact list including the header.
+func artefactTable(artefacts []Artefact) [][]string {
+	columns := selectedListColumns(artefacts)
+
+	header := make([]string, 0, len(columns))
+	for _, c := range columns {
+		header = append(header, c.header)
+	}
+
+	table := [][]string{header}
+	for _, a := range artefacts {
+		row := make([]string, 0, len(columns))
+		for _, c := range columns {
+			row = append(row, c.value(a))
+		}
+		table = append(table, row)
+	}
+
+	return table
+}
+
+// outdatedArtefacts returns the artefacts which are not at their target
+// version.
+func outdatedArtefacts(artefacts []Artefact) []Artefact {
+	var outdated []Artefact
+	for _, a := range artefacts {
+		if a.NeedsUpdate() {
+			outdated = append(outdated, a)
+		}
+	}
+	return outdated
+}
+
+// sortArtefacts sorts the artefacts by the column selected with -sort.
+func sortArtefacts(artefacts []Artefact) {
+	if listSort == "" {
+		return
+	}
+
+	c, _ := lookupListColumn(listSort)
+	compare := c.compare
+	if compare == nil {
+		compare = func(a, b Artefact) int { return strings.Compare(c.value(a), c.value(b)) }
+	}
+	slices.SortStableFunc(artefacts, compare)
+}
+
+// targetLabel returns the target version of the artefact with markers for pins
+// and binaries the current user can't replace.
+func targetLabel(a Artefact) string {
+	target := a.TargetVersion()
+	if a.Pinned() {
+		target += " (pinned)"
+	}
+	if a.NeedsUpdate() && notReplaceable(a) != nil {
+		target += " (not replaceable)"
+	}
+	return target
+}
+
+// releaseTimes maps the executable paths of binaries to the release time of
+// their target version, see lookupReleaseTimes.
+var releaseTimes = make(map[string]time.Time)
+
+// lookupReleaseTimes looks up the release times of the target versions of the
+// binaries. Failures are only logged, the release time is left out then.
+func lookupReleaseTimes(ctx context.Context, artefacts []Artefact) {
+	var mu sync.Mutex
+	parallel(jobs, len(artefacts), func(i int) {
+		a := artefacts[i]
+		if _, ok := a.(*binary); !ok || a.TargetVersion() == update.DevelVersion {
+			return
+		}
+
+		t, err := internal.VersionTime(ctx, a.ModulePath(), a.TargetVersion())
+		if err != nil {
+			slog.Debug("looking up release time failed", "path", a.ExecutablePath(), internal.AttrErr(err))
+			return
+		}
+
+		mu.Lock()
+		releaseTimes[a.ExecutablePath()] = t
+		mu.Unlock()
+	})
+}
+
+// targetReleases maps the executable paths of binaries hosted on GitHub to the
+// release of their target version, see lookupGitHubReleases.
+var targetReleases = make(map[string]*githubRelease)
+
+// lookupGitHubReleases looks up the GitHub releases of the target versions of
+// the binaries. Failures are only logged, e.g. an exhausted rate limit.
+func lookupGitHubReleases(ctx context.Context, artefacts []Artefact) {
+	var mu sync.Mutex
+	parallel(jobs, len(artefacts), func(i int) {
+		a := artefacts[i]
+		if _, ok := a.(*binary); !ok {
+			return
+		}
+
+		r, err := githubReleaseOf(ctx, a.ModulePath(), a.TargetVersion())
+		if err != nil {
+			slog.Warn("looking up GitHub release failed", "path", a.ExecutablePath(), internal.AttrErr(err))
+			return
+		}
+
+		mu.Lock()
+		targetReleases[a.ExecutablePath()] = r
+		mu.Unlock()
+	})
+}
+
+// releasedLabel returns how long ago the target version of the artefact has
+// been released, e.g. "3 days ago".
+func releasedLabel(a Artefact) string {
+	t, ok := releaseTimes[a.ExecutablePath()]
+	if !ok {
+		return ""
+	}
+	return formatAge(time.Since(t))
+}
+
+// formatAge formats the age of a release in the largest whole unit.
+func formatAge(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit + " ago"
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+
+	days := int(d.Hours() / 24)
+	switch {
+	case d < time.Hour:
+		return "just now"
+	case days < 1:
+		return plural(int(d.Hours()), "hour")
+	case days < 31:
+		return plural(days, "day")
+	case days < 365:
+		return plural(days/30, "month")
+	default:
+		return plural(days/365, "year")
+	}
+}
+
+// fileSize returns the size of the executable of the artefact in bytes, or -1
+// if it can't be determined.
+func fileSize(a Artefact) int64 {
+	info, err := os.Stat(a.ExecutablePath())
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
+
+// formatSize formats a size in bytes with a binary unit, e.g. 12.3 MiB.
+func formatSize(size int64) string {
+	if size < 0 {
+		return ""
+	}
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// compareVersions orders module versions semantically and go versions of
+// toolchains by their release, anything else is compared as a string.
+func compareVersions(a, b string) int {
+	if semver.IsValid(a) && semver.IsValid(b) {
+		return semver.Compare(a, b)
+	}
+	if strings.HasPrefix(a, "go") && strings.HasPrefix(b, "go") {
+		return internal.CompareGoVersions(a, b)
+	}
+	return strings.Compare(a, b)
+}
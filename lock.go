@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// waitLock waits for another running go-update to finish instead of exiting.
+var waitLock bool
+
+// lockPollInterval is the time between two attempts to acquire the run lock
+// while waiting for it.
+const lockPollInterval = 500 * time.Millisecond
+
+// errLocked is returned by tryLock if another process holds the lock.
+var errLocked = errors.New("locked")
+
+// lockPath returns the path of the run lock, which is held by commands
+// modifying GOBIN, so overlapping runs don't replace the same binaries or the
+// go link at the same time.
+func lockPath() string {
+	return filepath.Join(goBin, stateDir, "lock")
+}
+
+// acquireLock acquires the run lock. If another process holds it, an error is
+// returned, or with wait it is retried until ctx is done. The returned
+// function releases the lock.
+func acquireLock(ctx context.Context, wait bool) (release func(), err error) {
+	path := lockPath()
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("create run lock: %w", err)
+	}
+
+	waiting := false
+	for {
+		f, err := tryLock(path)
+		if err == nil {
+			// The pid is only informational, the lock is held by the open file.
+			_ = f.Truncate(0)
+			_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+			return func() { _ = f.Close() }, nil
+		} else if !errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("acquire run lock: %w", err)
+		}
+
+		holder := "another go-update"
+		if pid := lockHolder(path); pid != "" {
+			holder += " (pid " + pid + ")"
+		}
+		if !wait {
+			return nil, fmt.Errorf("%s is running, use -wait to wait for it to finish", holder)
+		}
+		if !waiting {
+			slog.Info("waiting for " + holder + " to finish")
+			waiting = true
+		}
+
+		select {
+		case <-time.After(lockPollInterval):
+		case <-ctx.Done():
+			return nil, errInterrupted
+		}
+	}
+}
+
+// lockHolder returns the pid written to the lock file by the process holding
+// it, or "" if it is unknown.
+func lockHolder(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
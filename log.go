@@ -5,21 +5,68 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"os"
 	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorGray   = "\033[90m"
 )
 
 type consoleHandler struct {
 	w      io.Writer
+	mu     *sync.Mutex
 	l      slog.Leveler
 	attrs  []slog.Attr
 	prefix string
+	color  bool
 }
 
+// newConsoleHandler creates a handler writing human readable lines to w. They
+// are colorized if w is a terminal, unless $NO_COLOR is set.
 func newConsoleHandler(w io.Writer, level slog.Leveler) slog.Handler {
 	return &consoleHandler{
-		w: w,
-		l: level,
+		w:     w,
+		mu:    &sync.Mutex{},
+		l:     level,
+		color: useColor(w),
+	}
+}
+
+func useColor(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// colorize wraps s in the color if colors are enabled.
+func (h *consoleHandler) colorize(s, color string) string {
+	if !h.color || color == "" {
+		return s
+	}
+	return color + s + colorReset
+}
+
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return colorRed
+	case level >= slog.LevelWarn:
+		return colorYellow
+	case level < slog.LevelInfo:
+		return colorGray
+	default:
+		return ""
 	}
 }
 
@@ -29,15 +76,31 @@ func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
 
 func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
 	var attrs []string
+	installed := ""
 	r.AddAttrs(h.attrs...)
 	r.Attrs(func(attr slog.Attr) bool {
-		attrs = append(attrs, attr.String())
+		if attr.Key == "installed-version" {
+			installed = attr.Value.String()
+		}
+		return true
+	})
+	r.Attrs(func(attr slog.Attr) bool {
+		s := attr.String()
+		if (attr.Key == "target-version" && attr.Value.String() != installed) || attr.Key == "version" {
+			// highlight the version an artefact is changed to
+			s = h.colorize(s, colorGreen)
+		}
+		attrs = append(attrs, s)
 		return true
 	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	// Max length we anticipate for level: DEBUG+2
-	_, err := fmt.Fprintf(h.w, "%s [%-7s] %s\t%s\n",
+	_, err := fmt.Fprintf(h.w, "%s [%s] %s\t%s\n",
 		r.Time.Format(time.RFC3339),
-		r.Level.String(),
+		h.colorize(fmt.Sprintf("%-7s", r.Level.String()), levelColor(r.Level)),
 		r.Message,
 		strings.Join(attrs, "\t"))
 
@@ -62,9 +125,11 @@ func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 
 	return &consoleHandler{
 		w:      h.w,
+		mu:     h.mu,
 		l:      h.l,
 		attrs:  append(h.attrs, attrs...),
 		prefix: h.prefix,
+		color:  h.color,
 	}
 }
 
@@ -78,8 +143,74 @@ func (h *consoleHandler) WithGroup(group string) slog.Handler {
 
 	return &consoleHandler{
 		w:      h.w,
+		mu:     h.mu,
 		l:      h.l,
 		attrs:  attrs,
 		prefix: h.prefix + group + ".",
+		color:  h.color,
 	}
 }
+
+// flushMu ensures that buffered records of one artefact are written as a
+// single block.
+var flushMu sync.Mutex
+
+// bufferedHandler holds back records until they are flushed to the wrapped
+// handler. Handlers derived via WithAttrs and WithGroup share the buffer.
+type bufferedHandler struct {
+	slog.Handler
+	buf *recordBuffer
+}
+
+type recordBuffer struct {
+	mu      sync.Mutex
+	records []bufferedRecord
+}
+
+type bufferedRecord struct {
+	h slog.Handler
+	r slog.Record
+}
+
+func (h *bufferedHandler) Handle(_ context.Context, r slog.Record) error {
+	h.buf.mu.Lock()
+	defer h.buf.mu.Unlock()
+
+	h.buf.records = append(h.buf.records, bufferedRecord{h: h.Handler, r: r.Clone()})
+	return nil
+}
+
+func (h *bufferedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &bufferedHandler{Handler: h.Handler.WithAttrs(attrs), buf: h.buf}
+}
+
+func (h *bufferedHandler) WithGroup(group string) slog.Handler {
+	return &bufferedHandler{Handler: h.Handler.WithGroup(group), buf: h.buf}
+}
+
+func (b *recordBuffer) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	flushMu.Lock()
+	defer flushMu.Unlock()
+
+	for _, br := range b.records {
+		_ = br.h.Handle(context.Background(), br.r)
+	}
+	b.records = nil
+}
+
+// artefactLogger returns the logger for a single artefact. If artefacts are
+// processed concurrently the records are buffered until flush is called, so
+// the output of different artefacts does not interleave.
+func artefactLogger(path string) (log *slog.Logger, flush func()) {
+	if jobs <= 1 {
+		return slog.With("path", path), func() {}
+	}
+
+	buf := &recordBuffer{}
+	h := &bufferedHandler{Handler: slog.Default().Handler(), buf: buf}
+
+	return slog.New(h).With("path", path), buf.flush
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	logFileEnv = "GOUPDATE_LOG_FILE"
+
+	// logFileMaxSize is the size at which the log file is rotated.
+	logFileMaxSize = 10 << 20
+
+	// logFileKeep is the number of rotated log files kept next to the log
+	// file.
+	logFileKeep = 3
+)
+
+// logFile is the path of the file logs are written to in addition to stderr,
+// e.g. $XDG_STATE_HOME/go-update/log.
+var logFile string
+
+// useLogFile adds the log file to the default logger. Records are written to
+// it at least at level info, regardless of the console log level.
+func useLogFile(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	w, err := openRotatingFile(path, logFileMaxSize, logFileKeep)
+	if err != nil {
+		return err
+	}
+
+	file := newConsoleHandler(w, fileLevel{})
+	slog.SetDefault(slog.New(teeHandler{slog.Default().Handler(), file}))
+
+	return nil
+}
+
+// fileLevel is the level of the log file, it is info unless the log level is
+// lower.
+type fileLevel struct{}
+
+func (fileLevel) Level() slog.Level {
+	return min(logLevel.Level(), slog.LevelInfo)
+}
+
+// teeHandler passes records to multiple handlers.
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	hs := make(teeHandler, len(t))
+	for i, h := range t {
+		hs[i] = h.WithAttrs(attrs)
+	}
+	return hs
+}
+
+func (t teeHandler) WithGroup(group string) slog.Handler {
+	hs := make(teeHandler, len(t))
+	for i, h := range t {
+		hs[i] = h.WithGroup(group)
+	}
+	return hs
+}
+
+// rotatingFile appends to a file and rotates it once it exceeds its maximum
+// size. Rotated files get the suffixes .1 (the newest) to .<keep>.
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	max  int64
+	keep int
+	f    *os.File
+	size int64
+}
+
+func openRotatingFile(path string, max int64, keep int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, max: max, keep: keep}
+	return r, r.open()
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 && r.size+int64(len(p)) > r.max {
+		err := r.rotate()
+		if err != nil {
+			return 0, fmt.Errorf("rotate log file: %w", err)
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	err := r.f.Close()
+	if err != nil {
+		return err
+	}
+
+	for i := r.keep; i > 0; i-- {
+		src := r.path
+		if i > 1 {
+			src = fmt.Sprintf("%s.%d", r.path, i-1)
+		}
+		err = os.Rename(src, fmt.Sprintf("%s.%d", r.path, i))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return r.open()
+}
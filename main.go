@@ -1,29 +1,56 @@
-//go:build unix
-
 package main
 
 import (
-	"debug/buildinfo"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
 )
 
 const (
 	goBinEnv        = "GOBIN"
 	goPathEnv       = "GOPATH"
 	goMinVersionEnv = "GOMINVERSION"
-	homeEnv         = "HOME"
+	goProxyEnv      = "GOPROXY"
+	goNoProxyEnv    = "GONOPROXY"
+	goPrivateEnv    = "GOPRIVATE"
+	goToolchainEnv  = "GOTOOLCHAIN"
+	jobsEnv         = "GOUPDATE_JOBS"
+	cacheTTLEnv     = "GOUPDATE_CACHE_TTL"
+	retriesEnv      = "GOUPDATE_RETRIES"
+	retryDelayEnv   = "GOUPDATE_RETRY_DELAY"
+	timeoutEnv      = "GOUPDATE_TIMEOUT"
+	rateLimitEnv    = "GOUPDATE_RATE_LIMIT"
+	toolchainURLEnv = "GOUPDATE_TOOLCHAIN_URL"
+	cooldownEnv     = "GOUPDATE_COOLDOWN"
+	binDirsEnv      = "GOUPDATE_BIN_DIRS"
+	goCliEnv        = "GOUPDATE_GO"
+	githubTokenEnv  = "GITHUB_TOKEN"
 
 	ignorePath = ".goupdateignore"
+	pinsPath   = ".goupdatepins"
+	configPath = ".goupdate.json"
+
+	// stateDir is the directory in GOBIN where go-update keeps its state.
+	stateDir = ".go-update"
 )
 
 var (
@@ -32,25 +59,143 @@ var (
 	logLevel = &slog.LevelVar{}
 
 	// minGoVersion sets the minimum go version that the binaries have to be
-	// built with. From go1.18 on the full build info is included, however, some
-	// of it has been present with go1.17, and it might work with go1.17
-	// binaries as well.
-	minGoVersion = "go1.18"
+	// built with, see update.MinGoVersion.
+	minGoVersion = update.MinGoVersion
 
 	// goProxies contains the parsed list of the GOPROXY environment variable.
 	// It honors the definition at
 	// https://go.dev/ref/mod#environment-variables.
-	goProxies = []string{"https://proxy.golang.org", "direct"}
+	goProxies = internal.ParseGoProxy("https://proxy.golang.org,direct")
 
 	goBin string
 
+	// binDirs are additional directories containing binaries to update. The
+	// state of go-update, like pins and backups, is only kept in GOBIN.
+	binDirs []string
+
+	// scanDir replaces GOBIN, e.g. to update the binaries in /usr/local/bin
+	// of a container image. Additional bin directories are not scanned.
+	scanDir string
+
+	// goCli is the go command resolving and installing binaries, go in PATH
+	// unless configured otherwise.
 	goCli string
 
+	// jobs is the number of artefacts which are resolved and updated
+	// concurrently.
+	jobs = runtime.NumCPU()
+
+	// cacheTTL is the duration for which listed module versions are cached on
+	// disk. A value of zero disables the cache.
+	cacheTTL = time.Hour
+
+	// cooldown is the minimum age of a release before it becomes the target
+	// version. A value of zero disables it.
+	cooldown time.Duration
+
+	// retries is the number of times a network operation is retried after a
+	// transient failure, the delay before the first retry is retryDelay and
+	// doubles with each further one.
+	retries    = 3
+	retryDelay = time.Second
+
+	// artefactTimeout limits the time spent resolving and installing a single
+	// artefact, so a module hosted on an unreachable server doesn't stall the
+	// whole run. A value of zero disables it.
+	artefactTimeout = 10 * time.Minute
+
+	// rateLimit is the maximum average number of requests per second sent to
+	// module proxies by all jobs together. A value of zero disables it.
+	rateLimit float64
+
+	// dryRun prints the commands an update would execute instead of running
+	// them.
+	dryRun bool
+
 	excludePatterns []string
 	includePatterns []string
+
+	// pins maps binary names to the version they are pinned to.
+	pins map[string]string
+
+	cfg config
+
+	// prerelease allows prerelease target versions for all binaries, unless
+	// disabled for a binary in the config.
+	prerelease bool
+
+	// allowMajor upgrades binaries to newer major versions of their module.
+	allowMajor bool
+
+	// quiet suppresses all output except errors and completed updates, so
+	// scheduled runs only produce output if something changed.
+	quiet bool
+
+	// requestedVersions maps binary names to the version queries given on the
+	// command line, they take precedence over pins.
+	requestedVersions = make(map[string]string)
+
+	// allowDowngrade installs resolved target versions older than the
+	// installed ones.
+	allowDowngrade bool
+
+	// adoptDevel reinstalls development builds at the latest release.
+	adoptDevel bool
+
+	// allowReplaced updates binaries built with replace directives.
+	allowReplaced bool
+
+	// showMajor looks up newer major versions of modules without upgrading
+	// to them.
+	showMajor bool
 )
 
-type usageError error
+// goEnvKeys are the go environment variables go-update uses itself. The go
+// command reads all of them from its environment anyway, the module related
+// ones in internal.ModuleEnv are looked up along with them.
+var goEnvKeys = []string{goBinEnv, goPathEnv, goProxyEnv, goNoProxyEnv, goPrivateEnv}
+
+// goEnv holds the effective values of goEnvKeys and internal.ModuleEnv as
+// reported by go env, which includes the settings made with go env -w. It is
+// nil if go env failed.
+var goEnv map[string]string
+
+// lookupGoEnv returns the effective value of the go environment variable, or
+// its value in the environment if go env failed.
+func lookupGoEnv(key string) string {
+	if value, ok := goEnv[key]; ok {
+		return value
+	}
+	return os.Getenv(key)
+}
+
+// initError indicates that go-update could not be set up, e.g. because GOBIN
+// does not exist.
+type initError struct {
+	error
+}
+
+// usageError indicates that a command has been used incorrectly. The usage of
+// the command is printed along with the error.
+type usageError struct {
+	error
+}
+
+// errOutdated is returned by the check command if at least one artefact is
+// not at its target version.
+var errOutdated = errors.New("one or more artefacts are outdated")
+
+// errFailed is returned by the update command if at least one artefact could
+// not be loaded or updated, and by audit if at least one couldn't be scanned.
+var errFailed = errors.New("one or more artefacts failed")
+
+// errInterrupted is returned if a command has been stopped by SIGINT or
+// SIGTERM before it could finish.
+var errInterrupted = errors.New("interrupted")
+
+// errVulnerable is returned by the audit command if at least one artefact is
+// affected by a known vulnerability.
+var errVulnerable = errors.New("one or more artefacts are vulnerable")
 
 func init() {
 	var err error
@@ -62,13 +207,23 @@ func init() {
 			slog.Debug("init done",
 				goBinEnv, goBin,
 				goMinVersionEnv, minGoVersion,
-				"GOCLI", goCli,
+				jobsEnv, jobs,
+				cacheTTLEnv, cacheTTL,
+				retriesEnv, retries,
+				retryDelayEnv, retryDelay,
+				timeoutEnv, artefactTimeout,
+				rateLimitEnv, rateLimit,
+				toolchainURLEnv, toolchainURL,
+				cooldownEnv, cooldown,
+				binDirsEnv, binDirs,
 			)
 		}
 	}()
 
 	logLevelEnv, ok := os.LookupEnv("LOG")
-	if ok {
+	if ok && logLevelEnv == "quiet" {
+		quiet = true
+	} else if ok {
 		err = logLevel.UnmarshalText([]byte(logLevelEnv))
 		if err != nil {
 			return
@@ -78,184 +233,560 @@ func init() {
 	}
 	slog.SetDefault(slog.New(newConsoleHandler(os.Stderr, logLevel)))
 
+	logFile = os.Getenv(logFileEnv)
+
 	customMinGoVersion, ok := os.LookupEnv(goMinVersionEnv)
 	if ok {
 		minGoVersion = customMinGoVersion
 	}
 
-	goBin = os.Getenv(goBinEnv)
-	if goBin == "" && os.Getenv(goPathEnv) != "" {
-		goBin = filepath.Join(os.Getenv(goPathEnv), "bin")
+	// Settings made with go env -w are only known to the go command. The go
+	// env file is shared by all go versions, so it doesn't matter that the go
+	// command from the config file is not known yet.
+	if customGoCli, ok := os.LookupEnv(goCliEnv); ok {
+		internal.UseGo(customGoCli)
+	}
+	goEnv, err = internal.GoEnv(context.Background(), append(goEnvKeys, internal.ModuleEnv...)...)
+	if err != nil {
+		slog.Debug("reading go env failed, falling back to the environment", internal.AttrErr(err))
+		err = nil
+	} else {
+		internal.UseGoEnv(goEnv)
+	}
+
+	customGoProxy := lookupGoEnv(goProxyEnv)
+	if customGoProxy != "" {
+		goProxies = internal.ParseGoProxy(customGoProxy)
+	}
+	proxies := internal.HTTPProxies(goProxies)
+
+	var proxyURLs []string
+	for _, p := range proxies {
+		proxyURLs = append(proxyURLs, p.URL)
+	}
+	base, err := newTransport()
+	if err != nil {
+		return
+	}
+	transport, err := newAuthTransport(base, proxyURLs)
+	if err != nil {
+		return
+	}
+	client = &http.Client{Transport: transport}
+
+	internal.UseProxies(client, proxies, len(proxies) < len(goProxies))
+	internal.UseDirect(internal.DirectAllowed(goProxies))
+
+	// Like the go command, GONOPROXY defaults to GOPRIVATE. go env already
+	// reports it that way.
+	noProxy, ok := goEnv[goNoProxyEnv]
+	if !ok {
+		noProxy, ok = os.LookupEnv(goNoProxyEnv)
+	}
+	if !ok {
+		noProxy = os.Getenv(goPrivateEnv)
+	}
+	internal.UseNoProxy(noProxy)
+
+	customJobs, ok := os.LookupEnv(jobsEnv)
+	if ok {
+		jobs, err = strconv.Atoi(customJobs)
+		if err != nil {
+			err = fmt.Errorf("parse $%s: %w", jobsEnv, err)
+			return
+		}
+		if jobs < 1 {
+			err = fmt.Errorf("$%s must be at least 1", jobsEnv)
+			return
+		}
+	}
+
+	customRetries, ok := os.LookupEnv(retriesEnv)
+	if ok {
+		retries, err = strconv.Atoi(customRetries)
+		if err != nil {
+			err = fmt.Errorf("parse $%s: %w", retriesEnv, err)
+			return
+		}
+		if retries < 0 {
+			err = fmt.Errorf("$%s must not be negative", retriesEnv)
+			return
+		}
+	}
+
+	customRetryDelay, ok := os.LookupEnv(retryDelayEnv)
+	if ok {
+		retryDelay, err = time.ParseDuration(customRetryDelay)
+		if err != nil {
+			err = fmt.Errorf("parse $%s: %w", retryDelayEnv, err)
+			return
+		}
+	}
+	internal.UseRetries(retries, retryDelay)
+
+	customTimeout, ok := os.LookupEnv(timeoutEnv)
+	if ok {
+		artefactTimeout, err = time.ParseDuration(customTimeout)
+		if err != nil {
+			err = fmt.Errorf("parse $%s: %w", timeoutEnv, err)
+			return
+		}
+	}
+
+	customRateLimit, ok := os.LookupEnv(rateLimitEnv)
+	if ok {
+		rateLimit, err = strconv.ParseFloat(customRateLimit, 64)
+		if err != nil {
+			err = fmt.Errorf("parse $%s: %w", rateLimitEnv, err)
+			return
+		}
+	}
+
+	githubToken = os.Getenv(githubTokenEnv)
+
+	goBin = lookupGoEnv(goBinEnv)
+	if goBin == "" && lookupGoEnv(goPathEnv) != "" {
+		// like the go command, only the first entry of GOPATH is used
+		goBin = filepath.Join(filepath.SplitList(lookupGoEnv(goPathEnv))[0], "bin")
 	} else if goBin == "" && os.Getenv(homeEnv) != "" {
 		goBin = filepath.Join(os.Getenv(homeEnv), "go", "bin")
-	} else if goBin == "" {
-		err = fmt.Errorf("unable to determine GOBIN: $GOBIN, $GOPATH and $HOME are not set")
-		return
+	}
+}
+
+// useGoBin checks GOBIN and reads the files of go-update from it. It is called
+// after the flags have been parsed, as -dir replaces GOBIN.
+func useGoBin() error {
+	if goBin == "" {
+		return fmt.Errorf("unable to determine GOBIN: $GOBIN, $GOPATH and $%s are not set", homeEnv)
+	}
+
+	what := "$GOBIN"
+	if scanDir != "" {
+		what = "-dir"
 	}
 
 	fileInfo, err := os.Stat(goBin)
 	if err != nil {
-		err = fmt.Errorf("stat $GOBIN (%s): %s", goBin, err.Error())
-		return
+		return fmt.Errorf("stat %s (%s): %s", what, goBin, err.Error())
 	}
 	if !fileInfo.IsDir() {
-		err = fmt.Errorf("$GOBIN (%s) is not a directory", goBin)
-		return
+		return fmt.Errorf("%s (%s) is not a directory", what, goBin)
 	}
 
-	goCli, err = exec.LookPath("go")
+	err = readGoBinFiles()
 	if err != nil {
-		err = fmt.Errorf("looking up go cli path: %w", err)
-		return
+		return err
+	}
+
+	if cfg.CacheTTL != "" {
+		cacheTTL, err = time.ParseDuration(cfg.CacheTTL)
+		if err != nil {
+			return fmt.Errorf("parse cacheTTL in config file: %w", err)
+		}
+	}
+
+	customCacheTTL, ok := os.LookupEnv(cacheTTLEnv)
+	if ok {
+		cacheTTL, err = time.ParseDuration(customCacheTTL)
+		if err != nil {
+			return fmt.Errorf("parse $%s: %w", cacheTTLEnv, err)
+		}
+	}
+
+	if cfg.Toolchain != nil && cfg.Toolchain.URL != "" {
+		toolchainURL = cfg.Toolchain.URL
+	}
+
+	customToolchainURL, ok := os.LookupEnv(toolchainURLEnv)
+	if ok {
+		toolchainURL = customToolchainURL
+	}
+
+	if cfg.Cooldown != "" {
+		cooldown, err = time.ParseDuration(cfg.Cooldown)
+		if err != nil {
+			return fmt.Errorf("parse cooldown in config file: %w", err)
+		}
+	}
+
+	customCooldown, ok := os.LookupEnv(cooldownEnv)
+	if ok {
+		cooldown, err = time.ParseDuration(customCooldown)
+		if err != nil {
+			return fmt.Errorf("parse $%s: %w", cooldownEnv, err)
+		}
 	}
 
+	binDirs = cfg.BinDirs
+	customBinDirs, ok := os.LookupEnv(binDirsEnv)
+	if ok {
+		binDirs = filepath.SplitList(customBinDirs)
+	}
+
+	goCli = "go"
+	if cfg.Go != "" {
+		goCli = cfg.Go
+	}
+	customGoCli, ok := os.LookupEnv(goCliEnv)
+	if ok {
+		goCli = customGoCli
+	}
+	// Resolves names without a path separator in PATH and checks that the
+	// file is executable.
+	goCli, err = exec.LookPath(goCli)
+	if err != nil {
+		return fmt.Errorf("look up go command: %w", err)
+	}
+	internal.UseGo(goCli)
+	slog.Debug("using go command", "path", goCli)
+
+	if cacheTTL > 0 {
+		return useVersionCache()
+	}
+	return nil
+}
+
+// readGoBinFiles reads the ignore file, the pins and the config file from
+// GOBIN.
+func readGoBinFiles() error {
+	var err error
+
 	excludePatterns, includePatterns, err = ignoreFile(filepath.Join(goBin, ignorePath))
 	if err != nil {
-		err = fmt.Errorf("load ignore file: %w", err)
+		return fmt.Errorf("load ignore file: %w", err)
 	}
+
+	pins, err = readPins(filepath.Join(goBin, pinsPath))
+	if err != nil {
+		return fmt.Errorf("load pins file: %w", err)
+	}
+
+	cfg, err = readConfig(filepath.Join(goBin, configPath))
+	if err != nil {
+		return fmt.Errorf("load config file: %w", err)
+	}
+
+	return nil
 }
 
-func main() {
-	err := Main()
+// versionCachePath returns the path of the version cache in the user's cache
+// directory.
+func versionCachePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
 	if err != nil {
-		var usageErr usageError
-		if errors.As(err, &usageErr) {
-			fmt.Printf("Usage: %s [ update (default) | list ]\n", os.Args[0])
-		}
+		return "", err
+	}
+	return filepath.Join(cacheDir, "go-update", "versions.json"), nil
+}
+
+// useVersionCache enables the on-disk cache of module versions in the user's
+// cache directory.
+func useVersionCache() error {
+	p, err := versionCachePath()
+	if err != nil {
+		// not fatal, versions are just looked up every time
+		slog.Debug("version cache disabled", internal.AttrErr(err))
+		return nil
+	}
+
+	err = internal.UseCache(p, cacheTTL)
+	if err != nil {
+		return fmt.Errorf("load version cache: %w", err)
+	}
+
+	return nil
+}
 
+func main() {
+	err := Main()
+	var initErr initError
+	if errors.As(err, &initErr) {
+		fmt.Printf("error: init: %s\n", err.Error())
+		os.Exit(1) // exit code 1: error during init
+	} else if errors.Is(err, errOutdated) {
+		os.Exit(10) // exit code 10: check found outdated artefacts
+	} else if errors.Is(err, errVulnerable) {
+		os.Exit(11) // exit code 11: audit found vulnerable artefacts
+	} else if errors.Is(err, errFailed) {
+		os.Exit(12) // exit code 12: update failed for some artefacts
+	} else if errors.Is(err, errInterrupted) || errors.Is(err, context.Canceled) {
+		os.Exit(130) // exit code 130: interrupted by a signal, as reported by shells
+	} else if err != nil {
 		fmt.Printf("error: main: %s\n", err.Error())
 		os.Exit(2) // exit code 2: generic error during execution
 	}
 }
 
 func Main() error {
-	var list bool
-	if len(os.Args) > 2 {
-		return usageError(fmt.Errorf("only one argument can be provided"))
-	} else if len(os.Args) > 1 {
-		switch os.Args[1] {
-		case "update": // default, no-op
-		case "list":
-			list = true
-		default:
-			return usageError(fmt.Errorf("unknown command '%s'", os.Args[1]))
-		}
+	flags := newFlagSet(programName())
+	err := flags.Parse(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		printUsage(flags)
+		return nil
+	} else if err != nil {
+		printUsage(flags)
+		return err
 	}
 
-	entries, err := fs.ReadDir(os.DirFS(goBin), ".")
-	if err != nil {
-		return err
+	name := "update"
+	var args []string
+	if flags.NArg() > 0 {
+		name, args = flags.Arg(0), flags.Args()[1:]
 	}
 
-	var artefacts []Artefact
+	cmd := lookupCommand(name)
+	if cmd == nil {
+		printUsage(flags)
+		return fmt.Errorf("unknown command '%s'", name)
+	}
 
-	for _, entry := range entries {
-		executablePath := filepath.Join(goBin, entry.Name())
-		log := slog.With("path", executablePath)
+	cmdFlags := newFlagSet(name)
+	if cmd.flags != nil {
+		cmd.flags(cmdFlags)
+	}
 
-		if ignore(excludePatterns, includePatterns, entry.Name()) {
-			log.Debug("ignoring file")
-			continue
+	err = cmdFlags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		printCommandUsage(cmd, cmdFlags)
+		return nil
+	} else if err != nil {
+		err = usageError{err}
+	} else {
+		err = validateFlags()
+	}
+	if err == nil {
+		if scanDir != "" {
+			goBin = scanDir
 		}
-
-		if entry.IsDir() {
-			log.Info("skipping directory", "name", entry.Name())
-			continue
+		err = useGoBin()
+		if err != nil {
+			err = initError{err}
+		} else if scanDir != "" {
+			binDirs = nil
 		}
-
-		fileInfo, err := entry.Info()
+	}
+	if quiet {
+		logLevel.Set(slog.LevelError)
+	}
+	internal.UseRateLimit(rateLimit)
+	if events {
+		dryRunOut = os.Stderr
+	}
+	if err == nil && logFile != "" {
+		err = useLogFile(logFile)
 		if err != nil {
-			log.Error("reading file info failed", internal.AttrErr(err))
-			continue
+			err = fmt.Errorf("open log file: %w", err)
 		}
-
-		if !executable(fileInfo.Mode()) {
-			log.Info("skipping non-executable file")
-			continue
+	}
+	if err == nil {
+		// On the first signal, running commands are stopped and files are left
+		// in a consistent state. A second one terminates the process at once.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-ctx.Done()
+			stop()
+		}()
+		defer stop()
+
+		// Commands modifying GOBIN fail before the first binary if it is not
+		// writable, instead of failing for every single one.
+		if cmd.locks && !dryRun {
+			err = checkWritable(goBin)
 		}
-		if !fileInfo.Mode().Type().IsRegular() {
-			log.Info("skipping non-regular file")
-			continue
+		if err == nil && cmd.locks && !dryRun {
+			var release func()
+			release, err = acquireLock(ctx, waitLock)
+			if err == nil {
+				defer release()
+			}
 		}
-
-		execFile, err := os.Open(executablePath)
-		if err != nil {
-			log.Error("unable to open executable", internal.AttrErr(err))
-			continue
+		if err == nil {
+			err = cmd.run(ctx, cmdFlags.Args())
 		}
+	}
 
-		magic := make([]byte, 2)
-		_, err = execFile.ReadAt(magic, 0)
-		if err != nil {
-			log.Error("unable to read magic bytes from executable", internal.AttrErr(err))
-			continue
-		}
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		printCommandUsage(cmd, cmdFlags)
+	}
 
-		if string(magic) == "#!" {
-			log.Info("skipping shell script with shebang")
-			continue
-		}
+	return err
+}
 
-		info, err := buildinfo.Read(execFile)
-		if err != nil {
-			log.Error("reading build info failed", internal.AttrErr(err))
-			continue
-		}
-		if info.GoVersion < minGoVersion {
-			log.Error("go version too old to update", "go-version", info.GoVersion)
-			continue
-		}
+// binaryName strips the executable suffix from a file name in GOBIN. The
+// result is used to refer to binaries in arguments, pins and the config.
+func binaryName(fileName string) string {
+	return strings.TrimSuffix(fileName, exeSuffix)
+}
 
-		a, err := NewArtefact(info)
-		if err != nil {
-			log.Error("loading artefact failed", internal.AttrErr(err))
-			continue
-		}
-		artefacts = append(artefacts, a)
+// checkBinaryName fails if the name given by the user or read from a file
+// doesn't refer to a file directly in GOBIN, e.g. because it contains a path
+// separator or is the state directory.
+func checkBinaryName(name string) error {
+	switch {
+	case name == "", name == ".", name == "..", name == stateDir:
+		return fmt.Errorf("'%s' is not a binary name", name)
+	case strings.ContainsAny(name, "/"+string(filepath.Separator)):
+		return fmt.Errorf("'%s' is not a binary name, it contains a path separator", name)
+	}
+	return nil
+}
 
-		log.Info("loaded artefact",
-			"installed-version", a.InstalledVersion(),
-			"target-version", a.TargetVersion())
+// checkWritable fails if the current user can't create files in dir, e.g. in
+// /usr/local/bin without elevated permissions or on a read-only mount. Updates
+// replace binaries by writing new files, so nothing is attempted if this fails.
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".go-update-probe-")
+	if errors.Is(err, fs.ErrPermission) {
+		return fmt.Errorf("%s is not writable by the current user, run go-update with elevated permissions, e.g. sudo, to update it", dir)
+	} else if errors.Is(err, syscall.EROFS) {
+		return fmt.Errorf("%s is on a read-only file system, use check to only look for updates", dir)
+	} else if err != nil {
+		return fmt.Errorf("check whether %s is writable: %w", dir, err)
+	}
+	_ = f.Close()
+	return os.Remove(f.Name())
+}
 
-		if list || !a.NeedsUpdate() {
-			continue
-		}
+// binaryPath returns the path of the binary with the given name in GOBIN.
+func binaryPath(name string) string {
+	return filepath.Join(goBin, binaryName(name)+exeSuffix)
+}
 
-		err = a.Update()
-		if err != nil {
-			log.Error("installing target version failed", internal.AttrErr(err))
-			continue
+// dryRunOut receives the output of printDryRun.
+var dryRunOut io.Writer = os.Stdout
+
+// printDryRun prints the commands of a single update as one block.
+func printDryRun(cmds ...string) {
+	_, _ = fmt.Fprint(dryRunOut, strings.Join(cmds, "\n")+"\n")
+}
+
+// reportUpdate prints a completed update in quiet mode, where the log message
+// announcing it is suppressed.
+func reportUpdate(executablePath, from, to string) {
+	if quiet && !dryRun {
+		_, _ = fmt.Fprintf(humanOut(), "updated %s from %s to %s\n", executablePath, from, to)
+	}
+}
+
+func printArtefacts(artefacts []Artefact) {
+	tablePrint(artefactTable(artefacts))
+}
+
+// printMarkdown prints the table as a GitHub flavored Markdown table, the
+// first row is the header.
+func printMarkdown(table [][]string) {
+	for i, row := range table {
+		cells := make([]string, len(row))
+		for ci, cell := range row {
+			cells[ci] = strings.ReplaceAll(cell, "|", `\|`)
 		}
+		fmt.Println("| " + strings.Join(cells, " | ") + " |")
 
-		log.Info("updated artefact")
+		if i == 0 {
+			separator := make([]string, len(row))
+			for ci := range separator {
+				separator[ci] = "---"
+			}
+			fmt.Println("| " + strings.Join(separator, " | ") + " |")
+		}
 	}
+}
 
-	if list {
-		printArtefacts(artefacts)
+// printCSV prints the table as CSV, the first row is the header.
+func printCSV(table [][]string) error {
+	w := csv.NewWriter(os.Stdout)
+	err := w.WriteAll(table)
+	if err != nil {
+		return err
 	}
+	return w.Error()
+}
 
-	return nil
+// installedLabel returns the installed version of the artefact with a marker
+// for development builds.
+func installedLabel(a Artefact) string {
+	if isDevel(a) {
+		return a.InstalledVersion() + " (locally built)"
+	}
+	return a.InstalledVersion()
 }
 
-func executable(mode os.FileMode) bool {
-	return mode&0111 != 0
+type artefactJSON struct {
+	ModulePath        string     `json:"modulePath"`
+	InstallPath       string     `json:"installPath"`
+	InstalledVersion  string     `json:"installedVersion"`
+	TargetVersion     string     `json:"targetVersion"`
+	NeedsUpdate       bool       `json:"needsUpdate"`
+	Pinned            bool       `json:"pinned"`
+	Devel             bool       `json:"devel,omitempty"`
+	MajorUpdate       string     `json:"majorUpdate,omitempty"`
+	Deprecated        string     `json:"deprecated,omitempty"`
+	Retracted         string     `json:"retracted,omitempty"`
+	NotReplaceable    string     `json:"notReplaceable,omitempty"`
+	GoVersion         string     `json:"goVersion,omitempty"`
+	Size              int64      `json:"size,omitempty"`
+	Released          *time.Time `json:"released,omitempty"`
+	GitHubRelease     string     `json:"githubRelease,omitempty"`
+	Archived          string     `json:"archived,omitempty"`
+	Vulnerabilities   []string   `json:"vulnerabilities,omitempty"`
+	GoVulnerabilities []string   `json:"goVulnerabilities,omitempty"`
+	Duplicates        []string   `json:"duplicates,omitempty"`
+	Prerelease        bool       `json:"prerelease,omitempty"`
+	Assets            []string   `json:"assets,omitempty"`
 }
 
-func printArtefacts(artefacts []Artefact) {
-	var table [][]string
-	table = append(table, []string{"Program", "Installed Version", "Latest Version"})
+func printArtefactsJSON(artefacts []Artefact) error {
+	out := make([]artefactJSON, 0, len(artefacts))
 	for _, a := range artefacts {
-		table = append(table, []string{
-			a.InstallPath(),
-			a.InstalledVersion(),
-			a.TargetVersion(),
-		})
+		entry := artefactJSON{
+			ModulePath:       a.ModulePath(),
+			InstallPath:      a.InstallPath(),
+			InstalledVersion: a.InstalledVersion(),
+			TargetVersion:    a.TargetVersion(),
+			NeedsUpdate:      a.NeedsUpdate(),
+			Pinned:           a.Pinned(),
+			Devel:            isDevel(a),
+			MajorUpdate:      a.MajorUpdate(),
+			Deprecated:       a.Deprecated(),
+			Retracted:        a.Retracted(),
+			GoVersion:        builtWith(a),
+		}
+		if size := fileSize(a); size >= 0 {
+			entry.Size = size
+		}
+		if t, ok := releaseTimes[a.ExecutablePath()]; ok {
+			entry.Released = &t
+		}
+		entry.Archived = archivedLabel(a)
+		entry.Duplicates = duplicatePaths[a.ExecutablePath()]
+		osvMu.Lock()
+		entry.Vulnerabilities = vulnIDs[a.ExecutablePath()]
+		entry.GoVulnerabilities = stdlibVulnIDs[a.ExecutablePath()]
+		osvMu.Unlock()
+		if r := targetReleases[a.ExecutablePath()]; r != nil {
+			entry.GitHubRelease, entry.Prerelease = r.HTMLURL, r.Prerelease
+			for _, asset := range r.Assets {
+				entry.Assets = append(entry.Assets, asset.Name)
+			}
+		}
+		if err := notReplaceable(a); err != nil && a.NeedsUpdate() {
+			entry.NotReplaceable = err.Error()
+		}
+		out = append(out, entry)
 	}
 
-	tablePrint(table)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
 }
 
 func tablePrint(table [][]string) {
+	fmt.Print(strings.Join(tableLines(table), "\n") + "\n")
+}
+
+// tableLines formats the table with aligned columns, one string per row.
+func tableLines(table [][]string) []string {
 	var columnWidth []int
 
 	for _, row := range table {
@@ -279,8 +810,9 @@ func tablePrint(table [][]string) {
 		return b.String()
 	}
 
-	b := strings.Builder{}
+	lines := make([]string, 0, len(table))
 	for _, row := range table {
+		b := strings.Builder{}
 		for ci, column := range row {
 			b.WriteString(column)
 			if ci == len(row)-1 {
@@ -288,8 +820,8 @@ func tablePrint(table [][]string) {
 			}
 			b.WriteString(spaces(columnWidth[ci] - len(column) + 1))
 		}
-		b.WriteRune('\n')
+		lines = append(lines, b.String())
 	}
 
-	fmt.Print(b.String())
+	return lines
 }
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"moehl.dev/go-update/internal"
+)
+
+// notify sends a desktop notification summarizing the updates of a run.
+var notify bool
+
+// notifyRun sends the notifications about the updates recorded during the
+// run. Nothing is sent if there were none.
+func notifyRun() {
+	updates := takeUpdates()
+	if len(updates) == 0 {
+		return
+	}
+
+	if notify || cfg.Notify {
+		err := desktopNotify("go-update", updateSummary(updates))
+		if err != nil {
+			slog.Warn("sending desktop notification failed", internal.AttrErr(err))
+		}
+	}
+
+	if cfg.Webhook != nil && cfg.Webhook.URL != "" {
+		err := callWebhook(*cfg.Webhook, updates)
+		if err != nil {
+			slog.Warn("calling webhook failed", internal.AttrErr(err))
+		}
+	}
+}
+
+// webhookPayload is sent to webhooks in the json format.
+type webhookPayload struct {
+	Host    string         `json:"host"`
+	GoBin   string         `json:"gobin"`
+	Summary string         `json:"summary"`
+	Updates []historyEntry `json:"updates"`
+}
+
+// callWebhook posts the updates to the webhook.
+func callWebhook(wh webhookConfig, updates []historyEntry) error {
+	host, _ := os.Hostname()
+
+	var payload any = webhookPayload{
+		Host:    host,
+		GoBin:   goBin,
+		Summary: updateSummary(updates),
+		Updates: updates,
+	}
+	if wh.Format == "slack" {
+		payload = map[string]string{
+			"text": fmt.Sprintf("go-update on %s: %s", host, updateSummary(updates)),
+		}
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", res.Status)
+	}
+
+	return nil
+}
+
+// updateSummary describes the updated and failed binaries in a single line.
+func updateSummary(updates []historyEntry) string {
+	var updated, failed []string
+	for _, u := range updates {
+		if u.Error != "" {
+			failed = append(failed, u.Binary)
+		} else {
+			updated = append(updated, u.Binary+" "+u.To)
+		}
+	}
+
+	var parts []string
+	if len(updated) > 0 {
+		parts = append(parts, fmt.Sprintf("updated %d: %s", len(updated), strings.Join(updated, ", ")))
+	}
+	if len(failed) > 0 {
+		parts = append(parts, fmt.Sprintf("failed %d: %s", len(failed), strings.Join(failed, ", ")))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// desktopNotify shows a notification using notify-send on linux and
+// terminal-notifier or osascript on macOS.
+func desktopNotify(title, message string) error {
+	var c *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		if p, err := exec.LookPath("terminal-notifier"); err == nil {
+			c = exec.Command(p, "-title", title, "-message", message)
+		} else {
+			script := fmt.Sprintf("display notification %s with title %s", appleScriptQuote(message), appleScriptQuote(title))
+			c = exec.Command("osascript", "-e", script)
+		}
+	case "windows":
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	default:
+		c = exec.Command("notify-send", "--app-name", "go-update", title, message)
+	}
+
+	out, err := c.CombinedOutput()
+	if len(out) > 0 && err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func appleScriptQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
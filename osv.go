@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/semver"
+	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
+)
+
+var (
+	// checkVulns looks up known vulnerabilities of the installed versions of
+	// binaries in the OSV database.
+	checkVulns bool
+
+	// fixVulnerable only updates binaries whose installed version has known
+	// vulnerabilities.
+	fixVulnerable bool
+)
+
+// osvAPI is the base URL of the OSV API.
+const osvAPI = "https://api.osv.dev/v1"
+
+// checkVulnsEnabled reports whether vulnerabilities are looked up.
+func checkVulnsEnabled() bool {
+	return checkVulns || fixVulnerable
+}
+
+var (
+	osvMu sync.Mutex
+	// osvCache maps module@version to the IDs of its vulnerabilities, as
+	// multiple binaries may be built from the same module.
+	osvCache = make(map[string][]string)
+	// vulnIDs maps the executable paths of binaries to the IDs of the
+	// vulnerabilities of their installed version.
+	vulnIDs = make(map[string][]string)
+	// stdlibVulnIDs maps the executable paths of binaries to the IDs of the
+	// vulnerabilities of the standard library they have been built with.
+	stdlibVulnIDs = make(map[string][]string)
+)
+
+// lookupVulns records and returns the IDs of the known vulnerabilities of the
+// main module of the binary at its installed version. Only the main module is
+// queried, use audit to check the dependencies and the standard library.
+func lookupVulns(ctx context.Context, a Artefact) ([]string, error) {
+	if _, ok := a.(*binary); !ok || a.InstalledVersion() == update.DevelVersion {
+		return nil, nil
+	}
+
+	ids, err := osvQuery(ctx, a.ModulePath(), a.InstalledVersion())
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	osvMu.Lock()
+	vulnIDs[a.ExecutablePath()] = ids
+	osvMu.Unlock()
+	return ids, nil
+}
+
+// vulnsLabel returns the IDs of the vulnerabilities of the artefact.
+func vulnsLabel(a Artefact) string {
+	osvMu.Lock()
+	defer osvMu.Unlock()
+	return strings.Join(vulnIDs[a.ExecutablePath()], ", ")
+}
+
+// lookupStdlibVulns records and returns the IDs of the known vulnerabilities
+// of the standard library of the go version the binary has been built with.
+// They are fixed by rebuilding it with a newer go version, even if the binary
+// is at its latest version.
+func lookupStdlibVulns(ctx context.Context, a Artefact) ([]string, error) {
+	b, ok := a.(*binary)
+	if !ok {
+		return nil, nil
+	}
+	version, ok := stdlibVersion(b.GoVersion)
+	if !ok {
+		return nil, nil
+	}
+
+	ids, err := osvQuery(ctx, "stdlib", version)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	osvMu.Lock()
+	stdlibVulnIDs[a.ExecutablePath()] = ids
+	osvMu.Unlock()
+	return ids, nil
+}
+
+// stdlibVulnsLabel returns the IDs of the vulnerabilities of the standard
+// library of the artefact.
+func stdlibVulnsLabel(a Artefact) string {
+	osvMu.Lock()
+	defer osvMu.Unlock()
+	return strings.Join(stdlibVulnIDs[a.ExecutablePath()], ", ")
+}
+
+// stdlibVersion converts a go version to the semantic version the standard
+// library has in OSV, e.g. go1.22rc1 to 1.22.0-rc.1. It reports false for
+// development versions of go.
+func stdlibVersion(goVersion string) (string, bool) {
+	v, _, _ := strings.Cut(goVersion, " ")
+	v, ok := strings.CutPrefix(v, "go")
+	if !ok {
+		return "", false
+	}
+
+	pre := ""
+	if i := strings.IndexAny(v, "abcdefghijklmnopqrstuvwxyz"); i >= 0 {
+		v, pre = v[:i], v[i:]
+		if n := strings.IndexAny(pre, "0123456789"); n > 0 {
+			pre = pre[:n] + "." + pre[n:]
+		}
+		pre = "-" + pre
+	}
+	if strings.Count(v, ".") == 1 {
+		v += ".0"
+	}
+
+	return v + pre, semver.IsValid("v" + v + pre)
+}
+
+// osvQuery returns the IDs of the vulnerabilities affecting the version of the
+// module.
+func osvQuery(ctx context.Context, modulePath, version string) ([]string, error) {
+	key := modulePath + "@" + version
+
+	osvMu.Lock()
+	ids, ok := osvCache[key]
+	osvMu.Unlock()
+	if ok {
+		return ids, nil
+	}
+
+	// The Go ecosystem of OSV uses versions without the v prefix.
+	query, err := json.Marshal(map[string]any{
+		"package": map[string]string{"name": modulePath, "ecosystem": "Go"},
+		"version": strings.TrimPrefix(version, "v"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u := osvAPI + "/query"
+	var body []byte
+	err = internal.Retry(ctx, u, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(query))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = res.Body.Close() }()
+
+		if res.StatusCode != http.StatusOK {
+			return &internal.StatusError{URL: u, Status: res.Status, Code: res.StatusCode}
+		}
+
+		body, err = io.ReadAll(res.Body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var r struct {
+		Vulns []struct {
+			ID string `json:"id"`
+		} `json:"vulns"`
+	}
+	err = json.Unmarshal(body, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range r.Vulns {
+		ids = append(ids, v.ID)
+	}
+	slices.Sort(ids)
+
+	osvMu.Lock()
+	osvCache[key] = ids
+	osvMu.Unlock()
+	return ids, nil
+}
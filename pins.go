@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"debug/buildinfo"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"moehl.dev/go-update/pkg/update"
+)
+
+// readPins reads the pinned versions keyed by binary name from a file. Every
+// line consists of the name of a binary and the version it is pinned to, or a
+// version constraint like ^1.4 it is limited to. If the path does not exist, no
+// pins are returned.
+func readPins(path string) (map[string]string, error) {
+	pins := make(map[string]string)
+
+	r, err := os.Open(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unable to open pins file: %w", err)
+	} else if errors.Is(err, os.ErrNotExist) {
+		// no pins file
+		return pins, nil
+	}
+	defer func() { _ = r.Close() }()
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if len(l) == 0 || l[0] == '#' {
+			continue
+		}
+
+		fields := strings.Fields(l)
+		if len(fields) < 2 || (len(fields) > 2 && !update.IsConstraint(fields[1])) {
+			return nil, fmt.Errorf("invalid pin '%s': expected '<binary> <version>'", l)
+		}
+
+		pin := strings.Join(fields[1:], " ")
+		if update.IsConstraint(pin) {
+			_, err = update.ParseConstraint(pin)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pin '%s': %w", l, err)
+			}
+		}
+
+		pins[fields[0]] = pin
+	}
+
+	if s.Err() != nil {
+		return nil, fmt.Errorf("read pins file: %w", s.Err())
+	}
+
+	return pins, nil
+}
+
+// writePins replaces the pins file at path with the given pins.
+func writePins(path string, pins map[string]string) error {
+	names := make([]string, 0, len(pins))
+	for name := range pins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := strings.Builder{}
+	b.WriteString("# Managed by go-update, see `go-update pin` and `go-update unpin`.\n")
+	for _, name := range names {
+		b.WriteString(name + " " + pins[name] + "\n")
+	}
+
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// pin pins the binary given as the first argument to the version or version
+// constraint given as the second argument. If no version is given, the
+// installed version is used.
+func pin(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return usageError{fmt.Errorf("pin requires a binary and an optional version")}
+	}
+	if len(args) > 2 && !update.IsConstraint(args[1]) {
+		return usageError{fmt.Errorf("pin requires a binary and an optional version")}
+	}
+
+	name := binaryName(args[0])
+	err := checkBinaryName(name)
+	if err != nil {
+		return usageError{err}
+	}
+
+	bi, err := buildinfo.ReadFile(binaryPath(name))
+	if err != nil {
+		return fmt.Errorf("read build info of '%s': %w", name, err)
+	}
+
+	version := installedVersion(bi)
+	if len(args) >= 2 {
+		version = strings.Join(args[1:], " ")
+	}
+	if update.IsConstraint(version) {
+		_, err = update.ParseConstraint(version)
+		if err != nil {
+			return usageError{err}
+		}
+	}
+
+	pins[name] = version
+
+	return writePins(filepath.Join(goBin, pinsPath), pins)
+}
+
+// unpin removes the pins of all binaries given as arguments.
+func unpin(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageError{fmt.Errorf("unpin requires at least one binary")}
+	}
+
+	for _, name := range args {
+		name = binaryName(name)
+		if _, ok := pins[name]; !ok {
+			return fmt.Errorf("binary '%s' is not pinned", name)
+		}
+		delete(pins, name)
+	}
+
+	return writePins(filepath.Join(goBin, pinsPath), pins)
+}
@@ -0,0 +1,198 @@
+// Package goproxy is a client of the module proxy protocol, which is served by
+// GOPROXY endpoints like https://proxy.golang.org, see
+// https://go.dev/ref/mod#goproxy-protocol.
+//
+//	c := goproxy.Client{URL: "https://proxy.golang.org"}
+//	versions, err := c.ListVersions(ctx, "golang.org/x/tools/gopls")
+//
+// Module paths and versions are escaped as required by the protocol, so
+// modules with upper case letters like github.com/BurntSushi/toml can be
+// requested as is.
+package goproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// ErrNotFound is returned if the proxy does not know the module or version.
+var ErrNotFound = errors.New("not found")
+
+// StatusError is returned for unexpected HTTP status codes.
+type StatusError struct {
+	URL    string
+	Status string
+	Code   int
+}
+
+func (e *StatusError) Error() string {
+	return e.URL + ": unexpected status: " + e.Status
+}
+
+// Info is the metadata of a module version.
+type Info struct {
+	Version string
+	Time    time.Time
+}
+
+// Client requests a single module proxy.
+type Client struct {
+	// URL is the base URL of the proxy, e.g. https://proxy.golang.org.
+	URL string
+
+	// HTTPClient is used for requests, http.DefaultClient if it is nil.
+	HTTPClient *http.Client
+
+	// Logger receives the log records of requests, slog.Default() if it is
+	// nil.
+	Logger *slog.Logger
+}
+
+// ListVersions returns the tagged versions of the module known to the proxy,
+// sorted by semantic version. Pseudo-versions are not listed.
+func (c Client) ListVersions(ctx context.Context, modulePath string) ([]string, error) {
+	escaped, err := module.EscapePath(modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.get(ctx, escaped+"/@v/list")
+	if err != nil {
+		return nil, err
+	}
+
+	versions := strings.Fields(string(body))
+	semver.Sort(versions)
+
+	return versions, nil
+}
+
+// Latest returns the version the proxy considers the latest one. If the module
+// has no tagged versions, it is a pseudo-version of the latest commit.
+func (c Client) Latest(ctx context.Context, modulePath string) (Info, error) {
+	escaped, err := module.EscapePath(modulePath)
+	if err != nil {
+		return Info{}, err
+	}
+	return c.info(ctx, escaped+"/@latest")
+}
+
+// Info returns the metadata of the version of the module. The version may also
+// be a query the proxy resolves, like a branch name.
+func (c Client) Info(ctx context.Context, modulePath, version string) (Info, error) {
+	p, err := versionPath(modulePath, version, ".info")
+	if err != nil {
+		return Info{}, err
+	}
+	return c.info(ctx, p)
+}
+
+// GoMod returns the go.mod file of the version of the module.
+func (c Client) GoMod(ctx context.Context, modulePath, version string) ([]byte, error) {
+	p, err := versionPath(modulePath, version, ".mod")
+	if err != nil {
+		return nil, err
+	}
+	return c.get(ctx, p)
+}
+
+// Download writes the zip archive of the version of the module to w. The
+// archive is not verified against the checksum database.
+func (c Client) Download(ctx context.Context, modulePath, version string, w io.Writer) error {
+	p, err := versionPath(modulePath, version, ".zip")
+	if err != nil {
+		return err
+	}
+
+	body, err := c.open(ctx, p)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	_, err = io.Copy(w, body)
+	return err
+}
+
+func (c Client) info(ctx context.Context, path string) (Info, error) {
+	var info Info
+
+	body, err := c.get(ctx, path)
+	if err != nil {
+		return info, err
+	}
+
+	err = json.Unmarshal(body, &info)
+	return info, err
+}
+
+// versionPath returns the escaped path of a file of the module version
+// relative to the proxy URL, e.g. github.com/!burnt!sushi/toml/@v/v1.3.2.mod.
+func versionPath(modulePath, version, ext string) (string, error) {
+	escaped, err := module.EscapePath(modulePath)
+	if err != nil {
+		return "", err
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", err
+	}
+	return escaped + "/@v/" + escapedVersion + ext, nil
+}
+
+func (c Client) get(ctx context.Context, path string) ([]byte, error) {
+	body, err := c.open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+
+	return io.ReadAll(body)
+}
+
+// open requests the path relative to the proxy URL and returns the response
+// body.
+func (c Client) open(ctx context.Context, path string) (io.ReadCloser, error) {
+	url := strings.TrimSuffix(c.URL, "/") + "/" + path
+
+	log := c.Logger
+	if log == nil {
+		log = slog.Default()
+	}
+	log.Debug("requesting module proxy", "url", url)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone {
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("%s: %w", url, ErrNotFound)
+	} else if res.StatusCode != http.StatusOK {
+		_ = res.Body.Close()
+		return nil, &StatusError{URL: url, Status: res.Status, Code: res.StatusCode}
+	}
+
+	return res.Body, nil
+}
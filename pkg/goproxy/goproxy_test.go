@@ -0,0 +1,137 @@
+package goproxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// newTestClient returns a client of a proxy serving the given bodies by
+// request path, other paths are not found.
+func newTestClient(t *testing.T, bodies map[string]string) Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := bodies[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return Client{URL: srv.URL + "/", HTTPClient: srv.Client()}
+}
+
+func TestVersionPath(t *testing.T) {
+	tests := []struct {
+		module  string
+		version string
+		ext     string
+		want    string
+	}{
+		{"golang.org/x/tools", "v0.1.0", ".info", "golang.org/x/tools/@v/v0.1.0.info"},
+		{"github.com/BurntSushi/toml", "v1.3.2", ".mod", "github.com/!burnt!sushi/toml/@v/v1.3.2.mod"},
+		{"github.com/Azure/azure-sdk-for-go", "v1.0.0-RC1", ".zip", "github.com/!azure/azure-sdk-for-go/@v/v1.0.0-!r!c1.zip"},
+		{"example.com/m", "master", ".info", "example.com/m/@v/master.info"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.module+"@"+tt.version, func(t *testing.T) {
+			got, err := versionPath(tt.module, tt.version, tt.ext)
+			if err != nil {
+				t.Fatalf("versionPath failed: %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("versionPath(%q, %q, %q) = %q, want %q", tt.module, tt.version, tt.ext, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionPathInvalid(t *testing.T) {
+	for _, m := range []string{"", "example.com/!m", "example.com/m\x00"} {
+		if _, err := versionPath(m, "v1.0.0", ".info"); err == nil {
+			t.Errorf("versionPath(%q) succeeded, want an error", m)
+		}
+	}
+}
+
+func TestListVersions(t *testing.T) {
+	c := newTestClient(t, map[string]string{
+		"/github.com/!burnt!sushi/toml/@v/list": "v1.10.0\nv1.2.0\n\nv1.9.0-rc.1\nv1.9.0\n",
+		"/example.com/empty/@v/list":            "",
+	})
+
+	tests := []struct {
+		module string
+		want   []string
+	}{
+		{"github.com/BurntSushi/toml", []string{"v1.2.0", "v1.9.0-rc.1", "v1.9.0", "v1.10.0"}},
+		{"example.com/empty", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.module, func(t *testing.T) {
+			got, err := c.ListVersions(context.Background(), tt.module)
+			if err != nil {
+				t.Fatalf("ListVersions failed: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListVersions(%q) = %v, want %v", tt.module, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInfo(t *testing.T) {
+	c := newTestClient(t, map[string]string{
+		"/github.com/!burnt!sushi/toml/@latest": `{"Version":"v1.3.2","Time":"2023-06-08T06:30:38Z"}`,
+	})
+
+	info, err := c.Latest(context.Background(), "github.com/BurntSushi/toml")
+	if err != nil {
+		t.Fatalf("Latest failed: %s", err)
+	}
+	if info.Version != "v1.3.2" || info.Time.Year() != 2023 {
+		t.Errorf("Latest = %+v, want v1.3.2 released in 2023", info)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		status   int
+		notFound bool
+	}{
+		{http.StatusNotFound, true},
+		{http.StatusGone, true},
+		{http.StatusInternalServerError, false},
+		{http.StatusForbidden, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			c := Client{URL: srv.URL, HTTPClient: srv.Client()}
+			_, err := c.ListVersions(context.Background(), "example.com/m")
+
+			if got := errors.Is(err, ErrNotFound); got != tt.notFound {
+				t.Fatalf("errors.Is(%v, ErrNotFound) = %t, want %t", err, got, tt.notFound)
+			}
+			if tt.notFound {
+				return
+			}
+
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.Code != tt.status {
+				t.Errorf("error %v is no StatusError with code %d", err, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package update
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// Constraint is a set of comparisons a version has to satisfy. It is parsed
+// from a space or comma separated list of constraints like ^1.4, ~0.12,
+// <2.0.0 or >=1.2 <1.5, all of which have to be met.
+type Constraint struct {
+	comparisons []comparison
+}
+
+type comparison struct {
+	// op is one of <, <=, >, >= and =.
+	op      string
+	version string
+}
+
+// constraintOps are the prefixes of constraints, longer ones first.
+var constraintOps = []string{"^", "~", ">=", "<=", ">", "<", "="}
+
+// IsConstraint reports whether s is a version constraint instead of a
+// version.
+func IsConstraint(s string) bool {
+	for _, op := range constraintOps {
+		if strings.HasPrefix(s, op) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseConstraint parses a version constraint. Versions may omit the v prefix
+// as well as the minor and patch version, which default to zero. A caret
+// allows changes which don't modify the left-most non-zero part, e.g. ^1.4
+// allows >=1.4.0 <2.0.0 and ^0.12 allows >=0.12.0 <0.13.0. A tilde allows patch
+// releases if the minor version is given, e.g. ~1.2 allows >=1.2.0 <1.3.0, and
+// minor releases otherwise.
+func ParseConstraint(s string) (Constraint, error) {
+	var c Constraint
+
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(fields) == 0 {
+		return c, fmt.Errorf("empty version constraint")
+	}
+
+	for _, f := range fields {
+		op := ""
+		for _, o := range constraintOps {
+			if strings.HasPrefix(f, o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return c, fmt.Errorf("invalid version constraint '%s': expected one of %s followed by a version", f, strings.Join(constraintOps, " "))
+		}
+
+		v := strings.TrimSpace(strings.TrimPrefix(f, op))
+		if !strings.HasPrefix(v, "v") {
+			v = "v" + v
+		}
+		if !semver.IsValid(v) {
+			return c, fmt.Errorf("invalid version constraint '%s': invalid version", f)
+		}
+		lower := semver.Canonical(v)
+
+		switch op {
+		case "^", "~":
+			c.comparisons = append(c.comparisons,
+				comparison{op: ">=", version: lower},
+				comparison{op: "<", version: upperBound(op, v)})
+		default:
+			c.comparisons = append(c.comparisons, comparison{op: op, version: lower})
+		}
+	}
+
+	return c, nil
+}
+
+// upperBound returns the first version excluded by a caret or tilde constraint
+// on the version v.
+func upperBound(op, v string) string {
+	parts := strings.Split(strings.TrimPrefix(semver.Canonical(v), "v"), "-")[0]
+	n := [3]int{}
+	for i, p := range strings.Split(parts, ".") {
+		n[i], _ = strconv.Atoi(p)
+	}
+	// given is the number of parts of the version in the constraint, the
+	// canonical version fills in the missing ones.
+	given := len(strings.Split(strings.Split(strings.TrimPrefix(v, "v"), "-")[0], "."))
+
+	switch {
+	case op == "~" && given >= 2:
+		return fmt.Sprintf("v%d.%d.0-0", n[0], n[1]+1)
+	case op == "~":
+		return fmt.Sprintf("v%d.0.0-0", n[0]+1)
+	case n[0] > 0 || given == 1:
+		return fmt.Sprintf("v%d.0.0-0", n[0]+1)
+	case n[1] > 0 || given == 2:
+		return fmt.Sprintf("v0.%d.0-0", n[1]+1)
+	default:
+		return fmt.Sprintf("v0.0.%d-0", n[2]+1)
+	}
+}
+
+// Matches reports whether the version satisfies all comparisons of the
+// constraint.
+func (c Constraint) Matches(version string) bool {
+	if !semver.IsValid(version) {
+		return false
+	}
+
+	for _, cmp := range c.comparisons {
+		r := semver.Compare(version, cmp.version)
+		ok := false
+		switch cmp.op {
+		case "<":
+			ok = r < 0
+		case "<=":
+			ok = r <= 0
+		case ">":
+			ok = r > 0
+		case ">=":
+			ok = r >= 0
+		case "=":
+			ok = r == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the versions satisfying the constraint.
+func (c Constraint) Filter(versions []string) []string {
+	var matching []string
+	for _, v := range versions {
+		if c.Matches(v) {
+			matching = append(matching, v)
+		}
+	}
+	return matching
+}
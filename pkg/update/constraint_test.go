@@ -0,0 +1,81 @@
+package update
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConstraintMatches(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"^1.4", "v1.4.0", true},
+		{"^1.4", "v1.9.3", true},
+		{"^1.4", "v1.3.9", false},
+		{"^1.4", "v2.0.0", false},
+		{"^1.4", "v2.0.0-rc.1", false},
+		{"^0.12", "v0.12.5", true},
+		{"^0.12", "v0.13.0", false},
+		{"^0.0.3", "v0.0.3", true},
+		{"^0.0.3", "v0.0.4", false},
+		{"^1", "v1.99.0", true},
+		{"^0", "v0.99.0", true},
+		{"^0", "v1.0.0", false},
+		{"~1.2", "v1.2.7", true},
+		{"~1.2", "v1.3.0", false},
+		{"~1.2.3", "v1.2.2", false},
+		{"~1.2.3", "v1.2.9", true},
+		{"~1", "v1.8.0", true},
+		{"~1", "v2.0.0", false},
+		{"<2.0.0", "v1.9.9", true},
+		{"<2.0.0", "v2.0.0", false},
+		{"<=2.0.0", "v2.0.0", true},
+		{"<=2.0.0", "v2.0.1", false},
+		{">=1.2", "v1.2.0", true},
+		{">=1.2", "v1.1.9", false},
+		{">1.2", "v1.2.0", false},
+		{">1.2", "v1.2.1", true},
+		{"=v1.2.3", "v1.2.3", true},
+		{"=v1.2.3", "v1.2.4", false},
+		{">=1.2 <1.5", "v1.4.9", true},
+		{">=1.2,<1.5", "v1.5.0", false},
+		{"^1.4", "not-a-version", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			if err != nil {
+				t.Fatalf("ParseConstraint(%q) failed: %s", tt.constraint, err)
+			}
+			if got := c.Matches(tt.version); got != tt.want {
+				t.Errorf("%q matches %s = %t, want %t", tt.constraint, tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConstraintInvalid(t *testing.T) {
+	for _, s := range []string{"", " , ", "1.2", "v1.2", "^", "^x.y", ">=1.2 1.5"} {
+		t.Run(s, func(t *testing.T) {
+			if _, err := ParseConstraint(s); err == nil {
+				t.Errorf("ParseConstraint(%q) succeeded, want an error", s)
+			}
+		})
+	}
+}
+
+func TestConstraintFilter(t *testing.T) {
+	c, err := ParseConstraint("^1.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions := []string{"v1.3.0", "v1.4.0", "v1.5.0-rc.1", "v1.5.0", "v2.0.0"}
+	want := []string{"v1.4.0", "v1.5.0-rc.1", "v1.5.0"}
+	if got := c.Filter(versions); !reflect.DeepEqual(got, want) {
+		t.Errorf("Filter(%v) = %v, want %v", versions, got, want)
+	}
+}
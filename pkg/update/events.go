@@ -0,0 +1,53 @@
+package update
+
+// Events are called at the steps of an update run, e.g. to report progress.
+// All of them are optional and may be called concurrently for different
+// binaries.
+type Events struct {
+	// OnArtefactFound is called for each Go binary found by a scan.
+	OnArtefactFound func(b Binary)
+
+	// OnResolved is called once the target version of an artefact is known.
+	OnResolved func(a Artefact)
+
+	// OnUpdateStart is called before an artefact is updated and OnUpdateDone
+	// after it, with the error of the update if it failed.
+	OnUpdateStart func(a Artefact)
+	OnUpdateDone  func(a Artefact, err error)
+
+	// OnError is called if the binary at path can't be read or resolved.
+	// Failed updates are reported by OnUpdateDone instead.
+	OnError func(path string, err error)
+}
+
+// The methods below call the events if they are set, e is allowed to be nil.
+
+func (e *Events) artefactFound(b Binary) {
+	if e != nil && e.OnArtefactFound != nil {
+		e.OnArtefactFound(b)
+	}
+}
+
+func (e *Events) resolved(a Artefact) {
+	if e != nil && e.OnResolved != nil {
+		e.OnResolved(a)
+	}
+}
+
+func (e *Events) updateStart(a Artefact) {
+	if e != nil && e.OnUpdateStart != nil {
+		e.OnUpdateStart(a)
+	}
+}
+
+func (e *Events) updateDone(a Artefact, err error) {
+	if e != nil && e.OnUpdateDone != nil {
+		e.OnUpdateDone(a, err)
+	}
+}
+
+func (e *Events) error(path string, err error) {
+	if e != nil && e.OnError != nil {
+		e.OnError(path, err)
+	}
+}
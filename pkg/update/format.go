@@ -0,0 +1,38 @@
+package update
+
+import (
+	"bytes"
+)
+
+// magics maps the leading bytes of executables to their format. Only formats
+// the Go toolchain can produce are listed.
+var magics = []struct {
+	magic  []byte
+	format string
+}{
+	{[]byte("\x7fELF"), "elf"},
+	{[]byte("\xfe\xed\xfa\xce"), "macho"}, // 32 bit, big endian
+	{[]byte("\xfe\xed\xfa\xcf"), "macho"}, // 64 bit, big endian
+	{[]byte("\xce\xfa\xed\xfe"), "macho"}, // 32 bit, little endian
+	{[]byte("\xcf\xfa\xed\xfe"), "macho"}, // 64 bit, little endian
+	{[]byte("\xca\xfe\xba\xbe"), "macho"}, // universal binary
+	{[]byte("MZ"), "pe"},
+	{[]byte("\x01\xdf"), "xcoff"},         // 32 bit
+	{[]byte("\x01\xf7"), "xcoff"},         // 64 bit
+	{[]byte("\x00\x00\x01\xeb"), "plan9"}, // 386
+	{[]byte("\x00\x00\x8a\x97"), "plan9"}, // amd64
+	{[]byte("\x00\x00\x06\x47"), "plan9"}, // arm
+	{[]byte("\x00asm"), "wasm"},
+}
+
+// executableFormat returns the format of the executable starting with the
+// given bytes, or the empty string if it is not one Go can build, e.g. a
+// script without shebang.
+func executableFormat(header []byte) string {
+	for _, m := range magics {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.format
+		}
+	}
+	return ""
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package update
+
+import (
+	"os"
+)
+
+// exeSuffix is the file name suffix of executables.
+const exeSuffix = ""
+
+func executable(_ string, mode os.FileMode) bool {
+	return mode&0111 != 0
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package update
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exeSuffix is the file name suffix of executables.
+const exeSuffix = ".exe"
+
+// executable on windows is determined by the file extension, there is no
+// executable bit.
+func executable(name string, _ os.FileMode) bool {
+	return strings.EqualFold(filepath.Ext(name), exeSuffix)
+}
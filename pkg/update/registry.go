@@ -0,0 +1,75 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// ArtefactKind describes a kind of artefact, like binaries installed with go
+// install or go toolchain wrappers.
+type ArtefactKind struct {
+	// Name identifies the kind, registering a kind with the same name again
+	// replaces it.
+	Name string
+
+	// Match reports whether the binary described by bi is of this kind.
+	Match func(bi *debug.BuildInfo) bool
+
+	// New creates the artefact described by bi which is installed at
+	// executablePath. Resolving its target version is aborted once ctx is
+	// done.
+	New func(ctx context.Context, executablePath string, bi *debug.BuildInfo, opts Options) (Artefact, error)
+}
+
+var (
+	kindsMu sync.RWMutex
+	kinds   []ArtefactKind
+)
+
+// RegisterArtefact registers a kind of artefact. Kinds are matched in reverse
+// order of registration, so a kind registered later takes precedence over a
+// more general one registered before, e.g. one matching all binaries.
+func RegisterArtefact(kind ArtefactKind) {
+	if kind.Name == "" || kind.Match == nil || kind.New == nil {
+		panic("update: artefact kind requires a name, a matcher and a constructor")
+	}
+
+	kindsMu.Lock()
+	defer kindsMu.Unlock()
+
+	for i, k := range kinds {
+		if k.Name == kind.Name {
+			kinds = append(kinds[:i], kinds[i+1:]...)
+			break
+		}
+	}
+	kinds = append(kinds, kind)
+}
+
+// NewArtefact creates the artefact described by bi which is installed at
+// executablePath with the latest registered kind matching it.
+func NewArtefact(ctx context.Context, executablePath string, bi *debug.BuildInfo, opts Options) (Artefact, error) {
+	if bi == nil {
+		return nil, fmt.Errorf("build info is nil")
+	}
+
+	kind, ok := matchKind(bi)
+	if !ok {
+		return nil, fmt.Errorf("no artefact kind matches %s", bi.Path)
+	}
+	return kind.New(ctx, executablePath, bi, opts)
+}
+
+func matchKind(bi *debug.BuildInfo) (ArtefactKind, bool) {
+	kindsMu.RLock()
+	defer kindsMu.RUnlock()
+
+	for i := len(kinds) - 1; i >= 0; i-- {
+		if kinds[i].Match(bi) {
+			return kinds[i], true
+		}
+	}
+	return ArtefactKind{}, false
+}
@@ -0,0 +1,520 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"moehl.dev/go-update/internal"
+)
+
+// Options control how the target version of an artefact is resolved.
+type Options struct {
+	// Pin is used as the target version instead of resolving it, if set.
+	Pin string
+
+	// Version is a version query like v1.2.3, v1.2 or a branch name. The
+	// version it resolves to is the target version, even if it is older than
+	// the installed one. It takes precedence over Pin and is not supported by
+	// go toolchains.
+	Version string
+
+	// Constraint limits the target version to the newest version satisfying
+	// it, e.g. ^1.4, ~0.12 or <2.0.0, see ParseConstraint. It has no effect
+	// on go toolchains.
+	Constraint string
+
+	// Branch is the branch tracked by binaries installed at a pseudo-version,
+	// e.g. master. It defaults to the default branch.
+	Branch string
+
+	// Cooldown is the minimum age of a release before it becomes the target
+	// version. Explicit and pinned versions are not affected.
+	Cooldown time.Duration
+
+	// Prerelease allows prerelease versions as target version. It has no
+	// effect on go toolchains, go.dev only announces stable releases.
+	Prerelease bool
+
+	// ProbeMajor enables the lookup of newer major versions of the module.
+	ProbeMajor bool
+
+	// AllowMajor makes a newer major version the target version. It implies
+	// ProbeMajor.
+	AllowMajor bool
+
+	// AdoptDevel makes the latest release the target version of development
+	// builds, which are left alone otherwise.
+	AdoptDevel bool
+
+	// AllowDowngrade makes a resolved target version older than the installed
+	// one, e.g. after installing a prerelease, the target anyway. Explicit and
+	// pinned versions are always installed.
+	AllowDowngrade bool
+
+	// AllowReplaced updates binaries built with replace directives. go install
+	// ignores them, so an update discards forks and local patches of modules.
+	// Such binaries are left alone otherwise.
+	AllowReplaced bool
+
+	// Series limits the target version of the go toolchain the go link points
+	// to to the patch releases of a minor version, e.g. 1.22. It has no effect
+	// on other binaries.
+	Series string
+}
+
+// Resolver resolves the target versions of binaries.
+type Resolver struct {
+	Options
+
+	// Logger receives the log records of the resolution, slog.Default() if it
+	// is nil.
+	Logger *slog.Logger
+}
+
+// Resolution is the outcome of resolving the target version of a binary.
+type Resolution struct {
+	// ModulePath is the module of the binary and PackagePath the path of its
+	// main package. Both are those of the newer major version if it is the
+	// target.
+	ModulePath  string
+	PackagePath string
+
+	Installed string
+	Target    string
+
+	// Pinned is set if the target is the pinned version, Requested if it has
+	// been given explicitly.
+	Pinned    bool
+	Requested bool
+
+	// Branch is the branch the binary tracks, if it is installed at a
+	// pseudo-version or from a branch.
+	Branch string
+
+	// Retracted is the rationale of the retraction of the installed version,
+	// or "retracted" if none is given. Deprecated is the deprecation message
+	// of the module.
+	Retracted  string
+	Deprecated string
+
+	// MajorModulePath and MajorVersion describe the latest release of a newer
+	// major version of the module, if there is one. Major is set if it is the
+	// target.
+	MajorModulePath string
+	MajorVersion    string
+	Major           bool
+
+	// Replaced lists the replace directives the binary has been built with.
+	Replaced []string
+
+	// Candidates are the versions of the module considered as target version,
+	// newest first. They are only set if the target has been resolved from
+	// the versions listed by the module proxy.
+	Candidates []Candidate
+
+	opts Options
+}
+
+// Candidate is a version of the module considered as target version.
+type Candidate struct {
+	Version string
+
+	// Rejected is the reason the version has not become the target, e.g.
+	// because it is a prerelease or has been retracted. It is empty for the
+	// target.
+	Rejected string
+}
+
+// reject records the reason the version has not become the target, unless an
+// earlier one has been recorded.
+func (r *Resolution) reject(version, reason string) {
+	for i, c := range r.Candidates {
+		if c.Version == version && c.Rejected == "" {
+			r.Candidates[i].Rejected = reason
+		}
+	}
+}
+
+// NeedsUpdate reports whether the target version differs from the installed
+// one. It is false for development builds unless they are adopted, for builds
+// with replace directives unless they are allowed and for resolved target
+// versions older than the installed one unless downgrades are allowed. Neither
+// a local build nor a newer version is replaced by accident.
+func (r *Resolution) NeedsUpdate() bool {
+	if r.Devel() && !r.opts.AdoptDevel {
+		return false
+	}
+	if len(r.Replaced) > 0 && !r.opts.AllowReplaced {
+		return false
+	}
+	if r.Downgrade() && !r.Pinned && !r.Requested && !r.opts.AllowDowngrade {
+		return false
+	}
+	return r.Target != r.Installed
+}
+
+// Devel reports whether the binary has been built from a local checkout.
+func (r *Resolution) Devel() bool {
+	return r.Installed == DevelVersion
+}
+
+// Downgrade reports whether the target version is older than the installed
+// one.
+func (r *Resolution) Downgrade() bool {
+	return IsDowngrade(r.Installed, r.Target)
+}
+
+// MajorUpdate returns the newer major version in the form path@version if
+// there is one and it is not the target.
+func (r *Resolution) MajorUpdate() string {
+	if r.Major || r.MajorVersion == "" {
+		return ""
+	}
+	return r.MajorModulePath + "@" + r.MajorVersion
+}
+
+// IsDowngrade reports whether target is an older version than installed.
+func IsDowngrade(installed, target string) bool {
+	return semver.IsValid(installed) && semver.IsValid(target) && semver.Compare(target, installed) < 0
+}
+
+// Resolve resolves the target version of the binary described by bi. Go
+// toolchain wrappers are not supported. Resolving is aborted once ctx is done.
+func (r Resolver) Resolve(ctx context.Context, bi *debug.BuildInfo) (*Resolution, error) {
+	if bi == nil {
+		return nil, fmt.Errorf("build info is nil")
+	}
+	if bi.Main.Path == ToolchainModule {
+		return nil, fmt.Errorf("go toolchains are not resolved from the module proxy")
+	}
+
+	log := logger(r.Logger)
+	res := &Resolution{
+		ModulePath:  bi.Main.Path,
+		PackagePath: bi.Path,
+		Installed:   bi.Main.Version,
+		Replaced:    ReplaceDirectives(bi),
+		opts:        r.Options,
+	}
+
+	if r.Version != "" {
+		target, err := internal.Query(ctx, bi.Main.Path, r.Version)
+		if err != nil {
+			return nil, err
+		}
+		res.Target = target
+		res.Requested = true
+		if IsBranchQuery(r.Version) {
+			res.Branch = r.Version
+		}
+		return res, nil
+	}
+
+	if r.Pin != "" {
+		res.Target = r.Pin
+		res.Pinned = true
+		return res, nil
+	}
+
+	if module.IsPseudoVersion(bi.Main.Version) && r.Constraint == "" {
+		err := r.resolveBranch(ctx, res)
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	versions, err := internal.ListVersions(ctx, bi.Main.Path)
+	if err != nil && res.Devel() && !r.AdoptDevel {
+		// Development builds are often of modules which have not been
+		// published, they are not updated anyway.
+		log.Debug("looking up versions of development build failed", "module", bi.Main.Path, internal.AttrErr(err))
+		res.Target = DevelVersion
+		return res, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	res.Candidates = make([]Candidate, 0, len(versions))
+	for _, v := range versions {
+		res.Candidates = append(res.Candidates, Candidate{Version: v})
+	}
+	slices.SortFunc(res.Candidates, func(a, b Candidate) int { return semver.Compare(b.Version, a.Version) })
+	for _, v := range versions {
+		if !semver.IsValid(v) {
+			res.reject(v, "not a valid semantic version")
+		} else if semver.Prerelease(v) != "" && !r.Prerelease {
+			res.reject(v, "prerelease")
+		}
+	}
+
+	info, err := internal.LatestModuleInfo(ctx, bi.Main.Path)
+	if err != nil {
+		log.Warn("looking up module info failed", "module", bi.Main.Path, internal.AttrErr(err))
+	} else {
+		res.Deprecated = info.Deprecated
+		versions = withoutRetracted(versions, info, res)
+		if retraction, ok := info.Retracted(bi.Main.Version); ok {
+			res.Retracted = retraction.Rationale
+			if res.Retracted == "" {
+				res.Retracted = "retracted"
+			}
+		}
+	}
+
+	if r.Constraint != "" {
+		c, err := ParseConstraint(r.Constraint)
+		if err != nil {
+			return nil, err
+		}
+		satisfying := c.Filter(versions)
+		for _, v := range versions {
+			if !slices.Contains(satisfying, v) {
+				res.reject(v, "does not satisfy "+r.Constraint)
+			}
+		}
+		versions = satisfying
+	}
+
+	target := LatestVersion(versions, r.Prerelease)
+	if target != "" && r.Cooldown > 0 {
+		target = r.cooledDown(ctx, bi.Main.Path, bi.Main.Version, versions, res)
+	}
+	if target == "" && r.Constraint != "" {
+		return nil, fmt.Errorf("no version of %s satisfies %s", bi.Main.Path, r.Constraint)
+	} else if target == "" {
+		// There are no suitable tagged versions, let the proxy decide.
+		target, err = internal.Latest(ctx, bi.Main.Path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	res.Target = target
+	for _, c := range res.Candidates {
+		if c.Version != target {
+			res.reject(c.Version, "older than "+target)
+		}
+	}
+
+	if r.ProbeMajor || r.AllowMajor {
+		res.MajorModulePath, res.MajorVersion = probeMajor(ctx, bi.Main.Path, r.Prerelease)
+		if res.MajorVersion != "" && r.AllowMajor && r.Constraint == "" {
+			if majorTarget := r.majorTarget(ctx, res.MajorModulePath, bi.Main.Version); majorTarget != "" {
+				res.Major = true
+				res.Target = majorTarget
+				for _, c := range res.Candidates {
+					res.reject(c.Version, "superseded by "+res.MajorModulePath+"@"+majorTarget)
+				}
+				res.ModulePath = res.MajorModulePath
+				res.PackagePath = res.MajorModulePath + strings.TrimPrefix(bi.Path, bi.Main.Path)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// majorTarget returns the target version within the newer major version of
+// the module. Retracted versions and versions within the cooldown are held
+// back like those of the installed major version. It returns the empty string
+// if all of them are held back.
+func (r Resolver) majorTarget(ctx context.Context, majorPath, installed string) string {
+	versions, err := internal.ListVersions(ctx, majorPath)
+	if err != nil {
+		return ""
+	}
+
+	// The reasons versions are held back are only recorded for the installed
+	// major version.
+	discard := &Resolution{}
+	info, err := internal.LatestModuleInfo(ctx, majorPath)
+	if err != nil {
+		logger(r.Logger).Warn("looking up module info failed", "module", majorPath, internal.AttrErr(err))
+	} else {
+		versions = withoutRetracted(versions, info, discard)
+	}
+
+	target := LatestVersion(versions, r.Prerelease)
+	if target != "" && r.Cooldown > 0 {
+		target = r.cooledDown(ctx, majorPath, installed, versions, discard)
+		if target == installed {
+			return ""
+		}
+	}
+	return target
+}
+
+// cooledDown returns the latest version which has been released at least the
+// cooldown ago. Only versions newer than the installed one are checked, if
+// all of them are held back the installed version is returned. Versions whose
+// release time is unknown are held back as well, the reasons are recorded in
+// res.
+func (r Resolver) cooledDown(ctx context.Context, modulePath, installed string, versions []string, res *Resolution) string {
+	log := logger(r.Logger)
+	remaining := append([]string(nil), versions...)
+	for {
+		v := LatestVersion(remaining, r.Prerelease)
+		if v == "" || semver.Compare(v, installed) <= 0 {
+			return installed
+		}
+
+		released, err := internal.VersionTime(ctx, modulePath, v)
+		if err == nil && time.Since(released) >= r.Cooldown {
+			return v
+		} else if err != nil {
+			log.Warn("looking up release time failed, holding back version", "module", modulePath, "version", v, internal.AttrErr(err))
+			res.reject(v, "release time unknown: "+err.Error())
+		} else {
+			log.Debug("holding back version within cooldown", "module", modulePath, "version", v, "released", released)
+			res.reject(v, fmt.Sprintf("released %s, within the cooldown of %s", released.Format(time.DateOnly), r.Cooldown))
+		}
+
+		remaining = slices.DeleteFunc(remaining, func(r string) bool { return r == v })
+	}
+}
+
+// DefaultBranch is the query resolving to the head of the default branch of a
+// module.
+const DefaultBranch = "HEAD"
+
+// commitPattern matches commit hashes and their prefixes.
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// IsBranchQuery reports whether the version query names a branch instead of a
+// version, a version prefix, a comparison or a commit.
+func IsBranchQuery(query string) bool {
+	return query != "" && query != "latest" && !semver.IsValid(query) &&
+		!strings.ContainsAny(query[:1], "<>") && !commitPattern.MatchString(query)
+}
+
+// resolveBranch makes the head of the tracked branch the target version of a
+// binary installed at a pseudo-version. With a cooldown, commits younger than
+// it are held back.
+func (r Resolver) resolveBranch(ctx context.Context, res *Resolution) error {
+	res.Branch = r.Branch
+	if res.Branch == "" {
+		res.Branch = DefaultBranch
+	}
+
+	target, err := internal.Query(ctx, res.ModulePath, res.Branch)
+	if err != nil {
+		return err
+	}
+
+	if r.Cooldown > 0 && module.IsPseudoVersion(target) {
+		committed, err := module.PseudoVersionTime(target)
+		if err == nil && time.Since(committed) < r.Cooldown {
+			logger(r.Logger).Debug("holding back commit within cooldown", "module", res.ModulePath, "version", target, "committed", committed)
+			target = res.Installed
+		}
+	}
+
+	logger(r.Logger).Debug("tracking branch", "module", res.ModulePath, "branch", res.Branch, "head", target)
+	res.Target = target
+	return nil
+}
+
+// probeMajor looks for newer major versions of the module by querying the
+// module paths with increasing major version suffixes until one does not
+// exist. It returns the module path and latest version of the highest major
+// version found.
+func probeMajor(ctx context.Context, modulePath string, prerelease bool) (majorPath, version string) {
+	if strings.HasPrefix(modulePath, "gopkg.in/") {
+		// gopkg.in encodes the major version differently and requires the
+		// version to be part of the path.
+		return "", ""
+	}
+
+	prefix, pathMajor, ok := module.SplitPathVersion(modulePath)
+	if !ok {
+		return "", ""
+	}
+
+	major := 1
+	if pathMajor != "" {
+		major, _ = strconv.Atoi(strings.TrimPrefix(pathMajor, "/v"))
+	}
+
+	for {
+		major++
+		p := fmt.Sprintf("%s/v%d", prefix, major)
+
+		versions, err := internal.ListVersions(ctx, p)
+		if err != nil {
+			// most likely the module does not exist
+			return majorPath, version
+		}
+
+		v := LatestVersion(versions, prerelease)
+		if v == "" {
+			return majorPath, version
+		}
+
+		majorPath, version = p, v
+	}
+}
+
+// withoutRetracted returns the versions which have not been retracted and
+// records the retracted ones in res.
+func withoutRetracted(versions []string, info internal.ModuleInfo, res *Resolution) []string {
+	var remaining []string
+	for _, v := range versions {
+		retraction, ok := info.Retracted(v)
+		if !ok {
+			remaining = append(remaining, v)
+			continue
+		}
+
+		reason := "retracted"
+		if retraction.Rationale != "" {
+			reason += ": " + retraction.Rationale
+		}
+		res.reject(v, reason)
+	}
+	return remaining
+}
+
+// LatestVersion returns the highest valid semantic version from versions.
+// Prerelease versions are only considered if prerelease is set. If no version
+// qualifies, the empty string is returned.
+func LatestVersion(versions []string, prerelease bool) string {
+	latest := ""
+	for _, v := range versions {
+		if !semver.IsValid(v) {
+			continue
+		}
+		if !prerelease && semver.Prerelease(v) != "" {
+			continue
+		}
+		if latest == "" || semver.Compare(v, latest) > 0 {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// ReplaceDirectives returns the replace directives the binary described by bi
+// has been built with in the form old => new.
+func ReplaceDirectives(bi *debug.BuildInfo) []string {
+	var directives []string
+	for _, m := range append([]*debug.Module{&bi.Main}, bi.Deps...) {
+		if m.Replace == nil {
+			continue
+		}
+		r := m.Replace.Path
+		if m.Replace.Version != "" && m.Replace.Version != DevelVersion {
+			r += "@" + m.Replace.Version
+		}
+		directives = append(directives, m.Path+" => "+r)
+	}
+	return directives
+}
@@ -0,0 +1,186 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"debug/buildinfo"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+)
+
+// MinGoVersion is the oldest go version binaries have to be built with. From
+// go1.18 on the full build info is included, however, some of it has been
+// present with go1.17, and it might work with go1.17 binaries as well.
+const MinGoVersion = "go1.18"
+
+// ErrNotGo is wrapped by the SkipError returned for executables which have not
+// been built by Go, like shell scripts or C programs.
+var ErrNotGo = errors.New("not a Go binary")
+
+// SkipError is returned by Read for files which are no Go binaries, like
+// directories or shell scripts.
+type SkipError struct {
+	Reason string
+
+	// Err is ErrNotGo for executables not built by Go, nil otherwise.
+	Err error
+}
+
+func (e *SkipError) Error() string {
+	return e.Reason
+}
+
+func (e *SkipError) Unwrap() error {
+	return e.Err
+}
+
+// Scanner finds the Go binaries in a directory.
+type Scanner struct {
+	// Dir is the directory to scan, usually GOBIN.
+	Dir string
+
+	// MinGoVersion overrides the package level MinGoVersion if set.
+	MinGoVersion string
+
+	// Skip reports whether the entry with the given file name is skipped, e.g.
+	// because it is ignored. It may be nil.
+	Skip func(name string) bool
+
+	// Logger receives the log records of the scan, slog.Default() if it is
+	// nil.
+	Logger *slog.Logger
+
+	// Events are called for the binaries found and files which can't be read,
+	// they may be nil.
+	Events *Events
+}
+
+// Scan returns the Go binaries in the directory, sorted by file name. Other
+// files are skipped, as well as binaries whose build info can't be read.
+// Scanning stops once ctx is done.
+func (s Scanner) Scan(ctx context.Context) ([]Binary, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var binaries []Binary
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return binaries, ctx.Err()
+		}
+		if s.Skip != nil && s.Skip(entry.Name()) {
+			continue
+		}
+
+		p := filepath.Join(s.Dir, entry.Name())
+		info, err := s.read(entry, p)
+		if err != nil {
+			logger(s.Logger).Debug("skipping file", "path", p, "reason", err)
+			if !IsSkip(err) {
+				s.Events.error(p, err)
+			}
+			continue
+		}
+		b := Binary{Path: p, Info: info}
+		s.Events.artefactFound(b)
+		binaries = append(binaries, b)
+	}
+
+	return binaries, nil
+}
+
+func (s Scanner) read(entry fs.DirEntry, p string) (*debug.BuildInfo, error) {
+	if entry.IsDir() {
+		return nil, &SkipError{Reason: "directory"}
+	}
+
+	fileInfo, err := entry.Info()
+	if err != nil {
+		return nil, fmt.Errorf("read file info: %w", err)
+	}
+
+	return readBinary(p, fileInfo, s.MinGoVersion)
+}
+
+// Read reads the build info of the Go binary at path. It returns a *SkipError
+// if the file is no Go binary, e.g. a directory, a non-executable file or a
+// shell script.
+func (s Scanner) Read(path string) (*debug.BuildInfo, error) {
+	fileInfo, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo.IsDir() {
+		return nil, &SkipError{Reason: "directory"}
+	}
+
+	return readBinary(path, fileInfo, s.MinGoVersion)
+}
+
+func readBinary(path string, fileInfo fs.FileInfo, minGoVersion string) (*debug.BuildInfo, error) {
+	if !executable(filepath.Base(path), fileInfo.Mode()) {
+		return nil, &SkipError{Reason: "non-executable file"}
+	}
+	if !fileInfo.Mode().Type().IsRegular() {
+		return nil, &SkipError{Reason: "non-regular file"}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open executable: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	header := make([]byte, 4)
+	n, err := f.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read magic bytes from executable: %w", err)
+	}
+	header = header[:n]
+	if bytes.HasPrefix(header, []byte("#!")) {
+		return nil, &SkipError{"shell script with shebang", ErrNotGo}
+	}
+	if executableFormat(header) == "" {
+		// e.g. scripts without shebang, which are run by the shell
+		return nil, &SkipError{"file of unknown executable format", ErrNotGo}
+	}
+
+	info, err := buildinfo.Read(f)
+	if err != nil && notGo(err) {
+		return nil, &SkipError{"executable not built by Go", ErrNotGo}
+	} else if err != nil {
+		return nil, fmt.Errorf("read build info: %w", err)
+	}
+
+	if minGoVersion == "" {
+		minGoVersion = MinGoVersion
+	}
+	if info.GoVersion < minGoVersion {
+		return nil, fmt.Errorf("go version %s too old to update", info.GoVersion)
+	}
+
+	return info, nil
+}
+
+// notGo reports whether err has been returned by buildinfo.Read for a file
+// which is no Go binary. The errors are not exported, so their messages are
+// compared.
+func notGo(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "not a Go executable") || strings.Contains(msg, "unrecognized file format")
+}
+
+// IsSkip reports whether err has been returned for a file which is no Go
+// binary.
+func IsSkip(err error) bool {
+	var skip *SkipError
+	return errors.As(err, &skip)
+}
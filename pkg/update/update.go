@@ -0,0 +1,113 @@
+// Package update finds Go binaries in a directory, resolves the versions they
+// can be updated to and installs them. It is the library behind go-update and
+// can be embedded by other tools instead of running the command:
+//
+//	binaries, err := update.Scanner{Dir: gobin}.Scan(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	for _, b := range binaries {
+//		if b.Toolchain() {
+//			continue
+//		}
+//		r, err := update.Resolver{}.Resolve(ctx, b.Info)
+//		if err != nil || !r.NeedsUpdate() {
+//			continue
+//		}
+//		err = update.Updater{Dir: gobin}.Update(ctx, b, r)
+//	}
+//
+// Versions are looked up from the module proxies, falling back to the go
+// command, which is also used to install binaries.
+//
+// Scanner, Resolver and Updater log to slog.Default() unless they are given a
+// Logger.
+package update
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+)
+
+// DevelVersion is the version of binaries built from a local checkout instead
+// of being installed from a module version.
+const DevelVersion = "(devel)"
+
+// ToolchainModule is the module go toolchain wrappers are installed from.
+const ToolchainModule = "golang.org/dl"
+
+// Artefact is something installed in a bin directory which go-update keeps up
+// to date.
+type Artefact interface {
+	// ExecutablePath is the location of the installed binary.
+	ExecutablePath() string
+
+	// ModulePath to look up available versions of the module.
+	ModulePath() string
+
+	// InstallPath is the module path plus the path to the main package inside
+	// the module. It can be used by `go install` together with a version.
+	InstallPath() string
+
+	// InstalledVersion is the currently installed version of the binary.
+	InstalledVersion() string
+
+	// TargetVersion that should be installed.
+	TargetVersion() string
+
+	// MajorUpdate returns the module path and latest version of a newer major
+	// version of the module in the form path@version, if one has been found
+	// and it is not already the target.
+	MajorUpdate() string
+
+	// Retracted returns the rationale of the retraction of the installed
+	// version, or "retracted" if none is given. It is empty if the installed
+	// version has not been retracted.
+	Retracted() string
+
+	// Deprecated returns the deprecation message of the module, if the module
+	// author deprecated it.
+	Deprecated() string
+
+	// Pinned reports whether the target version is pinned instead of being
+	// resolved.
+	Pinned() bool
+
+	// NeedsUpdate returns whether the artefact should be updated.
+	NeedsUpdate() bool
+
+	// Update installs the target version of the binary. The installation is
+	// aborted once ctx is done.
+	Update(ctx context.Context) error
+}
+
+// logger returns l, or the default logger if it is nil.
+func logger(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.Default()
+	}
+	return l
+}
+
+// Binary is a Go binary found by a Scanner.
+type Binary struct {
+	// Path is the location of the binary.
+	Path string
+
+	// Info is the build info embedded in the binary.
+	Info *debug.BuildInfo
+}
+
+// Name returns the file name of the binary without the executable suffix.
+func (b Binary) Name() string {
+	return strings.TrimSuffix(filepath.Base(b.Path), exeSuffix)
+}
+
+// Toolchain reports whether the binary is a go toolchain wrapper installed
+// from golang.org/dl. Their versions are not resolved by the Resolver.
+func (b Binary) Toolchain() bool {
+	return b.Info != nil && b.Info.Main.Path == ToolchainModule
+}
@@ -0,0 +1,201 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"moehl.dev/go-update/internal"
+)
+
+// InstallOptions control how go install builds a package.
+type InstallOptions struct {
+	// Flags are build flags like -tags or -ldflags, passed before the
+	// package.
+	Flags []string
+
+	// Env contains additional environment variables like CGO_ENABLED in the
+	// form key=value.
+	Env []string
+}
+
+// replayedFlags are the build flags recorded in the build info which are passed
+// to go install again, so an update is built like the installed binary.
+var replayedFlags = []string{"-buildmode", "-tags", "-trimpath", "-ldflags", "-gcflags", "-asmflags", "-race", "-msan", "-asan"}
+
+// replayedEnv are the environment variables recorded in the build info which
+// are set for go install again.
+var replayedEnv = []string{"CGO_ENABLED", "GOEXPERIMENT"}
+
+// BuildOptions returns the options go install needs to build the binary
+// described by bi the same way again. Flags at their default value are
+// omitted, environment variables like CGO_ENABLED=0 for static binaries are
+// kept.
+func BuildOptions(bi *debug.BuildInfo) InstallOptions {
+	settings := make(map[string]string, len(bi.Settings))
+	for _, s := range bi.Settings {
+		settings[s.Key] = s.Value
+	}
+
+	var opts InstallOptions
+	for _, key := range replayedFlags {
+		value := settings[key]
+		switch {
+		case value == "" || value == "false":
+		case key == "-buildmode" && value == "exe":
+		case value == "true":
+			opts.Flags = append(opts.Flags, key)
+		default:
+			opts.Flags = append(opts.Flags, key+"="+value)
+		}
+	}
+	for _, key := range replayedEnv {
+		if value, ok := settings[key]; ok {
+			opts.Env = append(opts.Env, key+"="+value)
+		}
+	}
+	return opts
+}
+
+// WithVersion returns the options to install the target version with if the
+// installed version has been built with these options. Version stamps in
+// -ldflags, like -X main.version=v1.2.3, are changed from the installed version
+// to the target. Development builds have no version to replace.
+func (o InstallOptions) WithVersion(installed, target string) InstallOptions {
+	if installed == DevelVersion {
+		return o
+	}
+
+	flags := make([]string, len(o.Flags))
+	for i, f := range o.Flags {
+		if ldflags, ok := strings.CutPrefix(f, "-ldflags="); ok && installed != "" && installed != target {
+			f = "-ldflags=" + replaceVersionStamps(ldflags, installed, target)
+		}
+		flags[i] = f
+	}
+	return InstallOptions{Flags: flags, Env: o.Env}
+}
+
+// replaceVersionStamps replaces the values of -X key=value definitions in the
+// linker flags which are the installed version, with or without the v prefix,
+// by the target version in the same form. Other values containing the version,
+// like -X main.minVersion=1.2.30, are kept.
+func replaceVersionStamps(ldflags, installed, target string) string {
+	replace := func(def string) string {
+		quote := ""
+		if len(def) >= 2 && (def[0] == '\'' || def[0] == '"') && def[len(def)-1] == def[0] {
+			quote, def = def[:1], def[1:len(def)-1]
+		}
+
+		key, value, ok := strings.Cut(def, "=")
+		switch {
+		case !ok:
+		case value == installed:
+			value = target
+		case value == strings.TrimPrefix(installed, "v"):
+			value = strings.TrimPrefix(target, "v")
+		}
+		if ok {
+			def = key + "=" + value
+		}
+		return quote + def + quote
+	}
+
+	fields := strings.Fields(ldflags)
+	for i, f := range fields {
+		name, def, hasDef := strings.Cut(f, "=")
+		switch {
+		case name != "-X" && name != "--X":
+		case hasDef:
+			fields[i] = f[:len(f)-len(def)] + replace(def)
+		case i+1 < len(fields):
+			fields[i+1] = replace(fields[i+1])
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// Command returns the command installing the version of the package with these
+// options, prefixed with the additional environment variables.
+func (o InstallOptions) Command(pkg, version string) string {
+	return internal.InstallCommand(pkg, version, internal.InstallOptions(o))
+}
+
+// Updater installs Go binaries with go install.
+type Updater struct {
+	// Dir is the directory binaries are installed to. If it is empty, GOBIN as
+	// configured for the go command is used.
+	Dir string
+
+	// Logger receives the log records of installs, slog.Default() if it is
+	// nil.
+	Logger *slog.Logger
+
+	// Events are called before and after updates, they may be nil.
+	Events *Events
+}
+
+// Install installs the version of the package. It is aborted once ctx is done.
+func (u Updater) Install(ctx context.Context, pkg, version string, opts InstallOptions) error {
+	logger(u.Logger).Debug("installing package", "command", opts.Command(pkg, version), "dir", u.Dir)
+	if u.Dir == "" {
+		return internal.Install(ctx, pkg, version, internal.InstallOptions(opts))
+	}
+	return internal.InstallTo(ctx, u.Dir, pkg, version, internal.InstallOptions(opts))
+}
+
+// Update installs the target version of the resolution, built the same way as
+// the binary. It is installed to Dir, or the directory of the binary if Dir is
+// empty. A running binary on Windows has to be moved aside first.
+func (u Updater) Update(ctx context.Context, b Binary, r *Resolution) error {
+	if b.Info == nil || r == nil {
+		return fmt.Errorf("binary or resolution is missing")
+	}
+
+	a := &resolvedBinary{u: u, b: b, r: r}
+	u.Events.updateStart(a)
+	err := u.update(ctx, b, r)
+	u.Events.updateDone(a, err)
+	return err
+}
+
+func (u Updater) update(ctx context.Context, b Binary, r *Resolution) error {
+	if u.Dir == "" {
+		u.Dir = filepath.Dir(b.Path)
+	}
+	opts := BuildOptions(b.Info).WithVersion(r.Installed, r.Target)
+	return u.Install(ctx, r.PackagePath, r.Target, opts)
+}
+
+// Artefact returns the resolved binary as an Artefact, which is updated by u.
+// OnResolved of the events is called with it.
+func (u Updater) Artefact(b Binary, r *Resolution) Artefact {
+	a := &resolvedBinary{u: u, b: b, r: r}
+	u.Events.resolved(a)
+	return a
+}
+
+// resolvedBinary is the Artefact of a binary and its resolution.
+type resolvedBinary struct {
+	u Updater
+	b Binary
+	r *Resolution
+}
+
+func (a *resolvedBinary) ExecutablePath() string   { return a.b.Path }
+func (a *resolvedBinary) ModulePath() string       { return a.r.ModulePath }
+func (a *resolvedBinary) InstallPath() string      { return a.r.PackagePath }
+func (a *resolvedBinary) InstalledVersion() string { return a.r.Installed }
+func (a *resolvedBinary) TargetVersion() string    { return a.r.Target }
+func (a *resolvedBinary) MajorUpdate() string      { return a.r.MajorUpdate() }
+func (a *resolvedBinary) Retracted() string        { return a.r.Retracted }
+func (a *resolvedBinary) Deprecated() string       { return a.r.Deprecated }
+func (a *resolvedBinary) Pinned() bool             { return a.r.Pinned }
+func (a *resolvedBinary) NeedsUpdate() bool        { return a.r.NeedsUpdate() }
+
+func (a *resolvedBinary) Update(ctx context.Context) error {
+	return a.u.Update(ctx, a.b, a.r)
+}
@@ -0,0 +1,41 @@
+package update
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithVersion(t *testing.T) {
+	tests := []struct {
+		ldflags string
+		want    string
+	}{
+		{"-s -w -X main.version=1.2.3", "-s -w -X main.version=1.3.0"},
+		{"-X main.version=v1.2.3", "-X main.version=v1.3.0"},
+		{"-X=main.version=1.2.3", "-X=main.version=1.3.0"},
+		{"--X main.version=1.2.3", "--X main.version=1.3.0"},
+		{"-X 'main.version=v1.2.3'", "-X 'main.version=v1.3.0'"},
+		{"-X main.minVersion=1.2.30", "-X main.minVersion=1.2.30"},
+		{"-X main.date=2024-01-02T1.2.3", "-X main.date=2024-01-02T1.2.3"},
+		{"-X main.version=1.2.3 -X main.min=1.2.3-rc.1", "-X main.version=1.3.0 -X main.min=1.2.3-rc.1"},
+		{"-extldflags=1.2.3 -s", "-extldflags=1.2.3 -s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ldflags, func(t *testing.T) {
+			opts := InstallOptions{Flags: []string{"-trimpath", "-ldflags=" + tt.ldflags}}
+			got := opts.WithVersion("v1.2.3", "v1.3.0").Flags
+			want := []string{"-trimpath", "-ldflags=" + tt.want}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("WithVersion(%q) = %q, want %q", tt.ldflags, got, want)
+			}
+		})
+	}
+}
+
+func TestWithVersionDevel(t *testing.T) {
+	opts := InstallOptions{Flags: []string{"-ldflags=-X main.version=(devel)"}}
+	if got := opts.WithVersion(DevelVersion, "v1.3.0"); !reflect.DeepEqual(got, opts) {
+		t.Errorf("WithVersion(%q) = %v, want %v", DevelVersion, got, opts)
+	}
+}
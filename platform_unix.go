@@ -0,0 +1,96 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+const (
+	homeEnv = "HOME"
+
+	// exeSuffix is the file name suffix of executables.
+	exeSuffix = ""
+
+	// netrcName is the name of the netrc file in the home directory.
+	netrcName = ".netrc"
+)
+
+func executable(_ string, mode os.FileMode) bool {
+	return mode&0111 != 0
+}
+
+// linkExecutable makes the executable target available under the path link.
+func linkExecutable(target, link string) error {
+	return os.Symlink(target, link)
+}
+
+// prepareReplace prepares the executable at path to be overwritten. The
+// returned function must be called with the outcome of the replacement. On
+// unix systems even running executables can be replaced directly.
+func prepareReplace(_ string) (done func(ok bool), err error) {
+	return func(bool) {}, nil
+}
+
+// checkReplaceable returns an error if the current user can't replace the
+// executable at path, because it is owned by another user or its directory is
+// not writable. Replacing a binary of another user would take it over, so it
+// is only done by root.
+func checkReplaceable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if uid := os.Getuid(); uid != 0 {
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Uid) != uid {
+			owner := strconv.Itoa(int(st.Uid))
+			if u, err := user.LookupId(owner); err == nil {
+				owner = u.Username
+			}
+			return fmt.Errorf("owned by %s", owner)
+		}
+	}
+
+	// W_OK, the syscall package does not define the constant on all systems.
+	err = syscall.Access(filepath.Dir(path), 0x2)
+	if err != nil {
+		return fmt.Errorf("%s is not writable", filepath.Dir(path))
+	}
+
+	return nil
+}
+
+// tryLock opens the file at path and locks it exclusively, the lock is
+// released by closing the file. It returns errLocked if another process holds
+// the lock.
+func tryLock(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// fcntl locks are available on all unix systems, unlike flock.
+	err = syscall.FcntlFlock(f.Fd(), syscall.F_SETLK, &syscall.Flock_t{Type: syscall.F_WRLCK})
+	if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EACCES) {
+		_ = f.Close()
+		return nil, errLocked
+	} else if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// isBusyError reports whether err has been caused by writing to a running
+// executable.
+func isBusyError(err error) bool {
+	return errors.Is(err, syscall.ETXTBSY)
+}
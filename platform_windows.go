@@ -0,0 +1,100 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// errSharingViolation is returned when opening a file that is in use, like a
+// running executable, for writing.
+const errSharingViolation syscall.Errno = 32
+
+const (
+	homeEnv = "USERPROFILE"
+
+	// exeSuffix is the file name suffix of executables.
+	exeSuffix = ".exe"
+
+	// netrcName is the name of the netrc file in the home directory.
+	netrcName = "_netrc"
+)
+
+// executable on windows is determined by the file extension, there is no
+// executable bit.
+func executable(name string, _ os.FileMode) bool {
+	return strings.EqualFold(filepath.Ext(name), exeSuffix)
+}
+
+// linkExecutable makes the executable target available under the path link.
+// Creating symlinks requires elevated privileges on windows, hard links don't.
+func linkExecutable(target, link string) error {
+	return os.Link(target, link)
+}
+
+// prepareReplace prepares the executable at path to be overwritten. The
+// returned function must be called with the outcome of the replacement.
+//
+// Windows does not allow writing to a running executable, but it can be
+// renamed. The executable is moved aside and restored if the replacement
+// fails.
+func prepareReplace(path string) (done func(ok bool), err error) {
+	old := path + ".old"
+
+	// Left over from a previous update where the executable was running.
+	_ = os.Remove(old)
+
+	err = os.Rename(path, old)
+	if errors.Is(err, os.ErrNotExist) {
+		return func(bool) {}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return func(ok bool) {
+		if !ok {
+			_ = os.Rename(old, path)
+			return
+		}
+		// Fails if the executable is still running, it will be removed by
+		// the next update instead.
+		_ = os.Remove(old)
+	}, nil
+}
+
+// checkReplaceable returns an error if the current user can't replace the
+// executable at path. Windows permissions are not checked, failures are
+// reported by the update instead.
+func checkReplaceable(_ string) error {
+	return nil
+}
+
+// tryLock opens the file at path without allowing others to write to it, which
+// serves as a lock released by closing the file. It returns errLocked if
+// another process holds the lock.
+func tryLock(path string) (*os.File, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := syscall.CreateFile(p, syscall.GENERIC_READ|syscall.GENERIC_WRITE, syscall.FILE_SHARE_READ,
+		nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if errors.Is(err, errSharingViolation) {
+		return nil, errLocked
+	} else if err != nil {
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(h), path), nil
+}
+
+// isBusyError reports whether err has been caused by writing to a running
+// executable.
+func isBusyError(err error) bool {
+	return errors.Is(err, errSharingViolation)
+}
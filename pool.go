@@ -0,0 +1,33 @@
+package main
+
+import (
+	"sync"
+)
+
+// parallel calls fn for every index in [0, n) using at most jobs goroutines
+// at the same time. It returns once all calls have returned.
+func parallel(jobs, n int, fn func(i int)) {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	work := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < jobs && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		work <- i
+	}
+	close(work)
+
+	wg.Wait()
+}
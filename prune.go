@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"debug/buildinfo"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
+)
+
+var (
+	// prune removes superseded toolchains after an update.
+	prune bool
+
+	// pruneKeep is the number of most recent toolchains of each series kept
+	// by prune.
+	pruneKeep = 1
+
+	toolchainPattern = regexp.MustCompile(`^go1(\.\d+)*((rc|beta)\d+)?$`)
+)
+
+// installedToolchain is a go version installed via golang.org/dl. Either the
+// wrapper in GOBIN or the SDK may be missing.
+type installedToolchain struct {
+	version string
+	wrapper string
+	sdk     string
+}
+
+func runPrune(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return usageError{fmt.Errorf("prune does not accept arguments")}
+	}
+	return pruneToolchains(pruneKeep)
+}
+
+// pruneToolchains removes the wrappers and SDKs of all go toolchains except the
+// keep most recent ones of each series, e.g. 1.22, and the one the go link in
+// GOBIN points to.
+func pruneToolchains(keep int) error {
+	if keep < 1 {
+		return usageError{fmt.Errorf("-keep must be at least 1")}
+	}
+
+	toolchains, err := installedToolchains()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(toolchains, func(i, j int) bool {
+		return internal.CompareGoVersions(toolchains[i].version, toolchains[j].version) > 0
+	})
+
+	kept := make(map[string]int)
+	for _, tc := range toolchains {
+		series := toolchainSeries(tc.version)
+		if kept[series] < keep || (tc.wrapper != "" && isGoLink(tc.wrapper)) {
+			kept[series]++
+			continue
+		}
+
+		err = removeToolchain(tc, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeToolchain removes the wrapper of the toolchain and, if sdk is set, its
+// SDK.
+func removeToolchain(tc *installedToolchain, sdk bool) error {
+	paths := []string{tc.wrapper}
+	if sdk {
+		paths = append(paths, tc.sdk)
+	}
+
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+
+		if dryRun {
+			printDryRun("rm -rf " + p)
+			continue
+		}
+
+		err := os.RemoveAll(p)
+		if err != nil {
+			return fmt.Errorf("remove %s: %w", p, err)
+		}
+		_, _ = fmt.Fprintf(humanOut(), "removed %s\n", p)
+	}
+
+	return nil
+}
+
+// installedToolchains finds the toolchain wrappers in GOBIN and the SDKs they
+// download into ~/sdk.
+func installedToolchains() ([]*installedToolchain, error) {
+	byVersion := make(map[string]*installedToolchain)
+	get := func(version string) *installedToolchain {
+		tc, ok := byVersion[version]
+		if !ok {
+			tc = &installedToolchain{version: version}
+			byVersion[version] = tc
+		}
+		return tc
+	}
+
+	entries, err := os.ReadDir(goBin)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := binaryName(entry.Name())
+		if !entry.Type().IsRegular() || !toolchainPattern.MatchString(name) {
+			continue
+		}
+
+		p := filepath.Join(goBin, entry.Name())
+		bi, err := buildinfo.ReadFile(p)
+		if err != nil || bi.Main.Path != update.ToolchainModule {
+			continue
+		}
+		get(name).wrapper = p
+	}
+
+	dir, err := sdkDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err = os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && toolchainPattern.MatchString(entry.Name()) {
+			get(entry.Name()).sdk = filepath.Join(dir, entry.Name())
+		}
+	}
+
+	toolchains := make([]*installedToolchain, 0, len(byVersion))
+	for _, tc := range byVersion {
+		toolchains = append(toolchains, tc)
+	}
+	return toolchains, nil
+}
+
+// sdkDir is the directory golang.org/dl downloads SDKs into.
+func sdkDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "sdk"), nil
+}
+
+// isGoLink reports whether the go link in GOBIN points to the wrapper.
+func isGoLink(wrapper string) bool {
+	goInfo, err := os.Stat(filepath.Join(goBin, "go"+exeSuffix))
+	if err != nil {
+		return false
+	}
+	wrapperInfo, err := os.Stat(wrapper)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(goInfo, wrapperInfo)
+}
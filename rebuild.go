@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"moehl.dev/go-update/internal"
+)
+
+// rebuildStale limits rebuild to binaries built with an older go version than
+// the installed one.
+var rebuildStale bool
+
+// runRebuild reinstalls binaries at their installed version, so they pick up
+// the fixes of the installed go version.
+func runRebuild(ctx context.Context, names []string) error {
+	goVersion, err := internal.GoVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	artefacts, err := loadArtefacts(ctx, names, false)
+	if err != nil {
+		return err
+	}
+
+	var binaries []*binary
+	for _, a := range artefacts {
+		b, ok := a.(*binary)
+		if !ok {
+			// go toolchains are not built from source
+			continue
+		}
+
+		log := slog.With("path", b.ExecutablePath())
+		if isDevel(b) {
+			log.Warn("skipping rebuild of development build")
+			continue
+		}
+		if len(b.res.Replaced) > 0 && !b.allowReplaced {
+			log.Warn("skipping rebuild of binary built with replace directives", "replaced", b.res.Replaced)
+			continue
+		}
+		if rebuildStale && internal.CompareGoVersions(b.GoVersion, goVersion) >= 0 {
+			log.Debug("skipping rebuild of current binary", "go-version", b.GoVersion)
+			continue
+		}
+
+		binaries = append(binaries, b)
+	}
+
+	var failed atomic.Int32
+	parallel(jobs, len(binaries), func(i int) {
+		b := binaries[i]
+		log, flush := artefactLogger(b.ExecutablePath())
+		defer flush()
+
+		ctx, cancel := withArtefactTimeout(ctx)
+		defer cancel()
+
+		start := time.Now()
+		err := timeoutError(b.rebuild(ctx))
+		recordUpdate(b.ExecutablePath(), b.InstalledVersion(), b.InstalledVersion(), start, err)
+		if err != nil {
+			log.Error("rebuilding binary failed", internal.AttrErr(err))
+			failed.Add(1)
+			return
+		}
+
+		log.Info("rebuilt binary", "old-go-version", b.GoVersion, "go-version", goVersion)
+		reportUpdate(b.ExecutablePath(), b.GoVersion, goVersion)
+	})
+
+	if failed.Load() > 0 {
+		return fmt.Errorf("rebuilding %d binaries failed", failed.Load())
+	}
+
+	return nil
+}
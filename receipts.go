@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
+)
+
+// receipt records how a binary has been installed by go-update. The next update
+// replays the flags and environment of the receipt instead of deriving them
+// from the build info, which lacks some of them, e.g. -ldflags of binaries
+// built with -trimpath. Branch is the branch the version has been resolved
+// from, if any.
+type receipt struct {
+	Package string    `json:"package"`
+	Version string    `json:"version"`
+	Branch  string    `json:"branch,omitempty"`
+	Command string    `json:"command"`
+	Flags   []string  `json:"flags,omitempty"`
+	Env     []string  `json:"env,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// receiptsDir returns the directory containing the receipts of all binaries.
+// The receipt of a binary is stored as <receiptsDir>/<binary>.json.
+func receiptsDir() string {
+	return filepath.Join(goBin, stateDir, "receipts")
+}
+
+func receiptPath(name string) string {
+	return filepath.Join(receiptsDir(), binaryName(name)+".json")
+}
+
+// readReceipt returns the receipt of the binary, or nil if there is none.
+func readReceipt(name string) (*receipt, error) {
+	b, err := os.ReadFile(receiptPath(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var r receipt
+	err = json.Unmarshal(b, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// writeReceipt replaces the receipt of the binary.
+func writeReceipt(name string, r receipt) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(receiptsDir(), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp := receiptPath(name) + ".tmp"
+	err = os.WriteFile(tmp, append(b, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, receiptPath(name))
+}
+
+// recordInstall writes the receipt of an install of the executable from the
+// given branch, which may be empty. Failing to write it is logged but not
+// returned, the install itself is not affected by it.
+func recordInstall(executablePath, pkg, version, branch string, opts update.InstallOptions) {
+	name := binaryName(filepath.Base(executablePath))
+	err := writeReceipt(name, receipt{
+		Package: pkg,
+		Version: version,
+		Branch:  branch,
+		Command: opts.Command(pkg, version),
+		Flags:   opts.Flags,
+		Env:     opts.Env,
+		Time:    time.Now().UTC(),
+	})
+	if err != nil {
+		slog.Warn("writing install receipt failed", "path", executablePath, internal.AttrErr(err))
+	}
+}
+
+// installedReceipt returns the receipt of the executable if it describes the
+// installed binary. Receipts of other versions, or older than the executable
+// because it has been replaced without go-update since, are ignored.
+func installedReceipt(executablePath string, bi debug.BuildInfo) *receipt {
+	r, err := readReceipt(filepath.Base(executablePath))
+	if err != nil {
+		slog.Warn("reading install receipt failed", "path", executablePath, internal.AttrErr(err))
+		return nil
+	}
+	if r == nil || r.Package != bi.Path || r.Version != bi.Main.Version {
+		return nil
+	}
+
+	info, err := os.Stat(executablePath)
+	if err != nil || info.ModTime().After(r.Time) {
+		return nil
+	}
+	return r
+}
+
+// buildSettings returns the build flags and environment to install the binary
+// with, taken from its receipt if there is a current one or otherwise from its
+// build info.
+func buildSettings(executablePath string, bi debug.BuildInfo) update.InstallOptions {
+	if r := installedReceipt(executablePath, bi); r != nil {
+		slog.Debug("replaying install receipt", "path", executablePath, "command", r.Command)
+		return update.InstallOptions{Flags: r.Flags, Env: r.Env}
+	}
+	return update.BuildOptions(&bi)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// removeRecord records removed binaries, so they are not restored again.
+var removeRecord bool
+
+// removedPath returns the path of the file listing the binaries removed with
+// -record, one name per line.
+func removedPath() string {
+	return filepath.Join(goBin, stateDir, "removed")
+}
+
+// readRemoved returns the names of the recorded removed binaries.
+func readRemoved() (map[string]bool, error) {
+	removed := make(map[string]bool)
+
+	f, err := os.Open(removedPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return removed, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if len(l) == 0 || l[0] == '#' {
+			continue
+		}
+		removed[l] = true
+	}
+
+	return removed, s.Err()
+}
+
+// writeRemoved replaces the list of removed binaries.
+func writeRemoved(removed map[string]bool) error {
+	names := make([]string, 0, len(removed))
+	for name := range removed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	err := os.MkdirAll(filepath.Dir(removedPath()), 0755)
+	if err != nil {
+		return err
+	}
+
+	b := strings.Builder{}
+	b.WriteString("# Managed by go-update, see `go-update remove -record`.\n")
+	for _, name := range names {
+		b.WriteString(name + "\n")
+	}
+
+	return os.WriteFile(removedPath(), []byte(b.String()), 0644)
+}
+
+// remove deletes the binaries given as arguments along with their backups and
+// pins.
+func remove(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageError{fmt.Errorf("remove requires at least one binary")}
+	}
+
+	removed, err := readRemoved()
+	if err != nil {
+		return fmt.Errorf("read removed binaries: %w", err)
+	}
+
+	for _, name := range args {
+		name = binaryName(name)
+
+		err = removeBinary(name)
+		if err != nil {
+			return err
+		}
+
+		if dryRun {
+			continue
+		}
+
+		if removeRecord {
+			removed[name] = true
+			err = writeRemoved(removed)
+			if err != nil {
+				return fmt.Errorf("record removal of '%s': %w", name, err)
+			}
+		}
+
+		fmt.Printf("removed %s\n", name)
+	}
+
+	return nil
+}
+
+// removeBinary deletes the binary, its backups, its receipt and its pin. Only
+// regular files and symlinks directly in GOBIN are removed.
+func removeBinary(name string) error {
+	err := checkBinaryName(name)
+	if err != nil {
+		return usageError{err}
+	}
+
+	p := binaryPath(name)
+	info, err := os.Lstat(p)
+	if err != nil {
+		return fmt.Errorf("remove '%s': %w", name, err)
+	}
+	if !info.Mode().IsRegular() && info.Mode()&fs.ModeSymlink == 0 {
+		return fmt.Errorf("remove '%s': %s is not a binary", name, p)
+	}
+
+	for _, path := range []string{p, receiptPath(name)} {
+		if dryRun {
+			printDryRun("rm -f " + path)
+			continue
+		}
+
+		err = os.Remove(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove '%s': %w", name, err)
+		}
+	}
+
+	backups := filepath.Join(backupsDir(), name)
+	if dryRun {
+		printDryRun("rm -rf " + backups)
+	} else if err = os.RemoveAll(backups); err != nil {
+		return fmt.Errorf("remove '%s': %w", name, err)
+	}
+
+	if _, ok := pins[name]; ok && !dryRun {
+		delete(pins, name)
+		err = writePins(filepath.Join(goBin, pinsPath), pins)
+		if err != nil {
+			return fmt.Errorf("unpin '%s': %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"moehl.dev/go-update/internal"
+)
+
+var (
+	// sbomFormat is the format of the bill of materials written by sbom,
+	// cyclonedx or spdx.
+	sbomFormat = "cyclonedx"
+
+	// sbomOutput is the file the bill of materials is written to by sbom,
+	// stdout if empty.
+	sbomOutput string
+)
+
+// sbomBinary is a binary described by the bill of materials.
+type sbomBinary struct {
+	path   string
+	info   *debug.BuildInfo
+	sha256 string
+}
+
+// sbomModule is a module compiled into a binary. If it has been replaced, it
+// is the replacement.
+type sbomModule struct {
+	path    string
+	version string
+}
+
+// purl returns the package URL of the module, which identifies it in both
+// formats.
+func (m sbomModule) purl() string {
+	if m.version == "" {
+		return "pkg:golang/" + m.path
+	}
+	// + of +incompatible and +dirty has to be percent-encoded
+	return "pkg:golang/" + m.path + "@" + strings.ReplaceAll(m.version, "+", "%2B")
+}
+
+// dependencies returns the modules compiled into the binary, excluding its
+// main module.
+func (b sbomBinary) dependencies() []sbomModule {
+	var deps []sbomModule
+	for _, d := range b.info.Deps {
+		if d.Replace != nil {
+			d = d.Replace
+		}
+		deps = append(deps, sbomModule{path: d.Path, version: d.Version})
+	}
+	return deps
+}
+
+func (b sbomBinary) module() sbomModule {
+	return sbomModule{path: b.info.Main.Path, version: installedVersion(b.info)}
+}
+
+func runSBOM(ctx context.Context, names []string) error {
+	if sbomFormat != "cyclonedx" && sbomFormat != "spdx" {
+		return usageError{fmt.Errorf("unknown format '%s', available are cyclonedx and spdx", sbomFormat)}
+	}
+
+	entries, err := readBinDirs()
+	if err != nil {
+		return err
+	}
+
+	if len(names) > 0 {
+		entries, err = selectEntries(entries, names)
+		if err != nil {
+			return err
+		}
+	}
+
+	binaries := make([]*sbomBinary, len(entries))
+	parallel(jobs, len(entries), func(i int) {
+		log := slog.With("path", entries[i].path())
+		info := readEntry(entries[i].dir, entries[i], log)
+		if info == nil {
+			return
+		}
+
+		sum, err := fileSHA256(entries[i].path())
+		if err != nil {
+			log.Warn("hashing binary failed", internal.AttrErr(err))
+		}
+		binaries[i] = &sbomBinary{path: entries[i].path(), info: info, sha256: sum}
+	})
+
+	var found []sbomBinary
+	for _, b := range binaries {
+		if b != nil {
+			found = append(found, *b)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if sbomOutput != "" {
+		f, err := os.Create(sbomOutput)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		w = f
+	}
+
+	var doc any
+	if sbomFormat == "spdx" {
+		doc = spdxDocument(found)
+	} else {
+		doc = cycloneDXDocument(found)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// cycloneDXDocument describes the binaries as applications and the modules
+// compiled into them as libraries in a CycloneDX 1.5 document.
+func cycloneDXDocument(binaries []sbomBinary) map[string]any {
+	var components, dependencies []map[string]any
+	libraries := make(map[string]bool)
+
+	for _, b := range binaries {
+		ref := "file:" + b.path
+		app := map[string]any{
+			"type":    "application",
+			"bom-ref": ref,
+			"name":    b.info.Path,
+			"version": installedVersion(b.info),
+			"purl":    b.module().purl(),
+			"properties": []map[string]string{
+				{"name": "path", "value": b.path},
+				{"name": "go:version", "value": b.info.GoVersion},
+			},
+		}
+		if b.sha256 != "" {
+			app["hashes"] = []map[string]string{{"alg": "SHA-256", "content": b.sha256}}
+		}
+		components = append(components, app)
+
+		dependsOn := []string{}
+		for _, m := range b.dependencies() {
+			purl := m.purl()
+			dependsOn = append(dependsOn, purl)
+			if libraries[purl] {
+				continue
+			}
+			libraries[purl] = true
+			components = append(components, map[string]any{
+				"type":    "library",
+				"bom-ref": purl,
+				"name":    m.path,
+				"version": m.version,
+				"purl":    purl,
+			})
+		}
+		dependencies = append(dependencies, map[string]any{"ref": ref, "dependsOn": dependsOn})
+	}
+
+	return map[string]any{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + newUUID(),
+		"version":      1,
+		"metadata": map[string]any{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"tools": map[string]any{
+				"components": []map[string]string{{"type": "application", "name": "go-update", "version": selfVersion()}},
+			},
+		},
+		"components":   components,
+		"dependencies": dependencies,
+	}
+}
+
+// spdxDocument describes the binaries and the modules compiled into them as
+// packages in an SPDX 2.3 document.
+func spdxDocument(binaries []sbomBinary) map[string]any {
+	var packages, relationships []map[string]any
+	ids := make(map[string]string)
+
+	pkg := func(name, version, purl string) map[string]any {
+		return map[string]any{
+			"name":             name,
+			"versionInfo":      version,
+			"downloadLocation": "NOASSERTION",
+			"filesAnalyzed":    false,
+			"externalRefs": []map[string]string{{
+				"referenceCategory": "PACKAGE-MANAGER",
+				"referenceType":     "purl",
+				"referenceLocator":  purl,
+			}},
+		}
+	}
+
+	for i, b := range binaries {
+		id := fmt.Sprintf("SPDXRef-Binary-%d-%s", i, spdxID(b.info.Path))
+		app := pkg(b.info.Path, installedVersion(b.info), b.module().purl())
+		app["SPDXID"] = id
+		app["primaryPackagePurpose"] = "APPLICATION"
+		app["comment"] = fmt.Sprintf("%s, built with %s", b.path, b.info.GoVersion)
+		if b.sha256 != "" {
+			app["checksums"] = []map[string]string{{"algorithm": "SHA256", "checksumValue": b.sha256}}
+		}
+		packages = append(packages, app)
+		relationships = append(relationships, map[string]any{
+			"spdxElementId":      "SPDXRef-DOCUMENT",
+			"relationshipType":   "DESCRIBES",
+			"relatedSpdxElement": id,
+		})
+
+		for _, m := range b.dependencies() {
+			purl := m.purl()
+			depID, ok := ids[purl]
+			if !ok {
+				depID = fmt.Sprintf("SPDXRef-Module-%d-%s", len(ids), spdxID(m.path+"-"+m.version))
+				ids[purl] = depID
+
+				lib := pkg(m.path, m.version, purl)
+				lib["SPDXID"] = depID
+				lib["primaryPackagePurpose"] = "LIBRARY"
+				packages = append(packages, lib)
+			}
+			relationships = append(relationships, map[string]any{
+				"spdxElementId":      id,
+				"relationshipType":   "DEPENDS_ON",
+				"relatedSpdxElement": depID,
+			})
+		}
+	}
+
+	return map[string]any{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              "go-update",
+		"documentNamespace": "https://moehl.dev/go-update/sbom/" + newUUID(),
+		"creationInfo": map[string]any{
+			"created":  time.Now().UTC().Format(time.RFC3339),
+			"creators": []string{"Tool: go-update-" + selfVersion()},
+		},
+		"packages":      packages,
+		"relationships": relationships,
+	}
+}
+
+// spdxInvalid matches the characters not allowed in SPDX identifiers.
+var spdxInvalid = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+func spdxID(s string) string {
+	return spdxInvalid.ReplaceAllString(s, "-")
+}
+
+// newUUID returns a random version 4 UUID, which makes the serial number and
+// namespace of each document unique.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// selfVersion returns the version of go-update, as recorded in its build info.
+func selfVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "unknown"
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+
+	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
+)
+
+// runStats counts the outcomes of the artefacts processed by loadArtefacts.
+var runStats struct {
+	updated, upToDate, failed, skipped atomic.Int32
+	// notGo counts the executables skipped because they have not been built by
+	// Go, they are not included in skipped.
+	notGo atomic.Int32
+}
+
+// resetRunStats resets runStats before a new run.
+func resetRunStats() {
+	runStats.updated.Store(0)
+	runStats.upToDate.Store(0)
+	runStats.failed.Store(0)
+	runStats.skipped.Store(0)
+	runStats.notGo.Store(0)
+}
+
+// runSummary describes runStats in a single line.
+func runSummary() string {
+	notGo := runStats.notGo.Load()
+	s := fmt.Sprintf("%d updated, %d up to date, %d failed, %d skipped",
+		runStats.updated.Load(), runStats.upToDate.Load(), runStats.failed.Load(), runStats.skipped.Load()+notGo)
+	if notGo > 0 {
+		s += fmt.Sprintf(" (%d not Go)", notGo)
+	}
+	if dryRun {
+		s = "dry run: " + s
+	}
+	return s
+}
+
+// loadArtefacts loads the artefacts of all binaries in GOBIN and the
+// additional bin directories, or only of those named, and installs their
+// target versions if install is set.
+//
+// Loading happens in two passes: first the build info of all binaries is read,
+// then the versions of all modules are prefetched at once before the
+// artefacts are resolved and updated individually.
+//
+// Once ctx is done, the remaining artefacts are skipped and errInterrupted is
+// returned after the ones in progress have finished.
+func loadArtefacts(ctx context.Context, names []string, install bool) ([]Artefact, error) {
+	entries, err := readBinDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) > 0 {
+		entries, err = selectEntries(entries, names)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resetRunStats()
+	emit(event{Type: eventScanStart, Path: goBin})
+
+	logs := make([]*slog.Logger, len(entries))
+	flushes := make([]func(), len(entries))
+	infos := make([]*debug.BuildInfo, len(entries))
+	parallel(jobs, len(entries), func(i int) {
+		logs[i], flushes[i] = artefactLogger(entries[i].path())
+		infos[i] = readEntry(entries[i].dir, entries[i], logs[i])
+		if infos[i] != nil && infos[i].Main.Path == update.ToolchainModule && entries[i].dir != goBin {
+			// the go link and the SDKs are managed relative to GOBIN
+			logs[i].Info("skipping toolchain wrapper outside of GOBIN")
+			explainf(entries[i].path(), "skipped: toolchain wrapper outside of GOBIN")
+			infos[i] = nil
+		}
+		if infos[i] != nil {
+			hooks.OnArtefactFound(update.Binary{Path: entries[i].path(), Info: infos[i]})
+		}
+	})
+
+	setting := loadToolchainSetting(ctx, infos, len(names) == 0)
+	if setting != nil {
+		for i, info := range infos {
+			if info != nil && info.Main.Path == update.ToolchainModule {
+				logs[i].Info("skipping toolchain wrapper, GOTOOLCHAIN selects the go version", "gotoolchain", setting.value())
+				explainf(entries[i].path(), "skipped: toolchain wrapper, GOTOOLCHAIN=%s selects the go version", setting.value())
+				infos[i] = nil
+			}
+		}
+	}
+
+	var modules []string
+	for i, info := range infos {
+		pin := pins[binaryName(entries[i].Name())]
+		if info != nil && info.Main.Path != update.ToolchainModule && (pin == "" || update.IsConstraint(pin)) {
+			modules = append(modules, info.Main.Path)
+		}
+	}
+	internal.PrefetchVersions(ctx, modules)
+
+	loaded := make([]Artefact, len(entries))
+	parallel(jobs, len(entries), func(i int) {
+		defer flushes[i]()
+		if infos[i] == nil {
+			return
+		}
+
+		executablePath := entries[i].path()
+		loaded[i] = processArtefact(ctx, executablePath, logs[i], install, func(ctx context.Context) (Artefact, error) {
+			return update.NewArtefact(ctx, executablePath, infos[i], artefactOptions(binaryName(entries[i].Name())))
+		})
+	})
+
+	if setting != nil && toolchainMode() == toolchainModeBump && len(names) == 0 {
+		loaded = append(loaded, processToolchainSetting(ctx, setting, install))
+	}
+
+	err = internal.SaveCache()
+	if err != nil {
+		slog.Warn("saving version cache failed", internal.AttrErr(err))
+	}
+
+	if ctx.Err() != nil {
+		return nil, errInterrupted
+	}
+
+	var artefacts []Artefact
+	for _, a := range loaded {
+		if a != nil {
+			artefacts = append(artefacts, a)
+		}
+	}
+
+	return artefacts, nil
+}
+
+// binEntry is an entry of GOBIN or one of the additional bin directories.
+type binEntry struct {
+	fs.DirEntry
+	dir string
+}
+
+func (e binEntry) path() string {
+	return filepath.Join(e.dir, e.Name())
+}
+
+// readDir returns the entries of the bin directory.
+func readDir(dir string) ([]binEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]binEntry, len(dirEntries))
+	for i, e := range dirEntries {
+		entries[i] = binEntry{DirEntry: e, dir: dir}
+	}
+	return entries, nil
+}
+
+// readBinDirs returns the entries of GOBIN followed by those of the additional
+// bin directories. Additional directories which can't be read are skipped.
+func readBinDirs() ([]binEntry, error) {
+	entries, err := readDir(goBin)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{filepath.Clean(goBin): true}
+	for _, dir := range binDirs {
+		if dir == "" || seen[filepath.Clean(dir)] {
+			continue
+		}
+		seen[filepath.Clean(dir)] = true
+
+		dirEntries, err := readDir(dir)
+		if err != nil {
+			slog.Warn("skipping unreadable bin directory", "dir", dir, internal.AttrErr(err))
+			continue
+		}
+		entries = append(entries, dirEntries...)
+	}
+	return entries, nil
+}
+
+// selectEntries returns the entries matching the given binary names in the
+// order of names. It fails if a name can't be found. If a name exists in
+// multiple bin directories, the first entry is selected.
+func selectEntries(entries []binEntry, names []string) ([]binEntry, error) {
+	byName := make(map[string]binEntry, len(entries))
+	for _, entry := range entries {
+		if _, ok := byName[binaryName(entry.Name())]; !ok {
+			byName[binaryName(entry.Name())] = entry
+		}
+	}
+
+	selected := make([]binEntry, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[binaryName(name)] {
+			continue
+		}
+		seen[binaryName(name)] = true
+
+		entry, ok := byName[binaryName(name)]
+		if !ok {
+			return nil, fmt.Errorf("binary '%s' not found in %s", name, strings.Join(append([]string{goBin}, binDirs...), ", "))
+		}
+		selected = append(selected, entry)
+	}
+
+	return selected, nil
+}
+
+// readEntry reads the build info of a single entry of the bin directory dir.
+// It returns nil if the entry is skipped.
+func readEntry(dir string, entry fs.DirEntry, log *slog.Logger) *debug.BuildInfo {
+	executablePath := filepath.Join(dir, entry.Name())
+
+	if ignore(excludePatterns, includePatterns, entry.Name()) {
+		log.Debug("ignoring file")
+		explainf(executablePath, "skipped: matches ignore pattern '%s'", strings.Join(matchingPatterns(excludePatterns, entry.Name()), "', '"))
+		return nil
+	}
+
+	if entry.IsDir() {
+		log.Info("skipping directory", "name", entry.Name())
+		return nil
+	}
+	if target, ok := brokenSymlink(binEntry{DirEntry: entry, dir: dir}); ok {
+		log.Warn("skipping broken symlink, remove it with clean", "target", target)
+		explainf(executablePath, "skipped: broken symlink to %s", target)
+		return nil
+	}
+
+	info, err := update.Scanner{MinGoVersion: minGoVersion}.Read(executablePath)
+	if errors.Is(err, update.ErrNotGo) {
+		log.Debug("skipping " + err.Error())
+		explainf(executablePath, "skipped: %s", err.Error())
+		runStats.notGo.Add(1)
+		return nil
+	} else if update.IsSkip(err) {
+		log.Info("skipping " + err.Error())
+		explainf(executablePath, "skipped: %s", err.Error())
+		return nil
+	} else if err != nil {
+		log.Error("reading build info failed", internal.AttrErr(err))
+		explainf(executablePath, "failed: reading build info: %s", err.Error())
+		return nil
+	}
+
+	return info
+}
+
+// withArtefactTimeout returns a context for resolving or installing a single
+// artefact which is done once the artefact timeout has passed.
+func withArtefactTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if artefactTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, artefactTimeout)
+}
+
+// timeoutError makes errors caused by the artefact timeout mention it.
+func timeoutError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s: %w", artefactTimeout, err)
+	}
+	return err
+}
+
+// processArtefact loads the artefact at executablePath with load and, if
+// update is set, installs its target version. It returns nil if loading the
+// artefact failed.
+func processArtefact(ctx context.Context, executablePath string, log *slog.Logger, update bool, load func(ctx context.Context) (Artefact, error)) Artefact {
+	if ctx.Err() != nil {
+		log.Debug("skipping artefact after interrupt")
+		runStats.skipped.Add(1)
+		return nil
+	}
+
+	lookupCtx, cancel := withArtefactTimeout(ctx)
+	a, err := load(lookupCtx)
+	cancel()
+	if err != nil && ctx.Err() != nil {
+		log.Debug("loading artefact interrupted", internal.AttrErr(err))
+		runStats.skipped.Add(1)
+		return nil
+	} else if err != nil {
+		err = timeoutError(err)
+		log.Error("loading artefact failed", internal.AttrErr(err))
+		explainf(executablePath, "failed: %s", err.Error())
+		hooks.OnError(executablePath, err)
+		runStats.failed.Add(1)
+		return nil
+	}
+
+	log.Info("loaded artefact",
+		"installed-version", a.InstalledVersion(),
+		"target-version", a.TargetVersion())
+	hooks.OnResolved(a)
+
+	if a.Deprecated() != "" {
+		log.Warn("module is deprecated", "message", a.Deprecated())
+	}
+	if _, ok := a.(*binary); ok && a.NeedsUpdate() {
+		if found := shadowedBy(executablePath); found != "" {
+			log.Warn("binary is shadowed by another one earlier in PATH, updating it has no visible effect", "shadowed-by", found)
+		}
+	}
+	if checkArchivedEnabled() {
+		repoURL, err := lookupArchived(ctx, a)
+		if err != nil {
+			log.Debug("looking up whether the repository is archived failed", internal.AttrErr(err))
+		} else if repoURL != "" {
+			log.Warn("repository is archived, the module will likely not receive updates anymore", "repository", repoURL)
+		}
+	}
+	var vulns []string
+	if checkVulnsEnabled() {
+		var err error
+		vulns, err = lookupVulns(ctx, a)
+		if err != nil {
+			log.Warn("looking up vulnerabilities failed", internal.AttrErr(err))
+		} else if len(vulns) > 0 {
+			log.Warn("installed version has known vulnerabilities", "vulnerabilities", vulns)
+		}
+
+		stdlibVulns, err := lookupStdlibVulns(ctx, a)
+		if err != nil {
+			log.Warn("looking up vulnerabilities of the standard library failed", internal.AttrErr(err))
+		} else if len(stdlibVulns) > 0 {
+			log.Warn("binary has been built with a go version with known vulnerabilities, rebuild it with a newer go version",
+				"go-version", builtWith(a),
+				"vulnerabilities", stdlibVulns)
+		}
+	}
+	if a.Retracted() != "" {
+		log.Warn("installed version has been retracted",
+			"installed-version", a.InstalledVersion(),
+			"rationale", a.Retracted())
+	}
+
+	if isDevel(a) && !a.NeedsUpdate() {
+		log.Info("skipping development build, use -adopt-devel to replace it with the latest release")
+		explainf(executablePath, "skipped: development build, -adopt-devel not set")
+		runStats.skipped.Add(1)
+		return a
+	}
+	if r := replaced(a); len(r) > 0 && !a.NeedsUpdate() && a.TargetVersion() != a.InstalledVersion() {
+		log.Warn("skipping binary built with replace directives, an update would discard them; use -allow-replaced or allowReplaced in the config to update it anyway",
+			"replaced", r)
+		explainf(executablePath, "skipped: built with replace directives, -allow-replaced not set")
+		runStats.skipped.Add(1)
+		return a
+	}
+	if isDowngrade(a) && !a.NeedsUpdate() {
+		log.Info("skipping downgrade to older target version, use -allow-downgrade to install it anyway")
+		explainf(executablePath, "held back: target %s is older than %s, -allow-downgrade not set", a.TargetVersion(), a.InstalledVersion())
+		runStats.skipped.Add(1)
+		return a
+	}
+	if !a.NeedsUpdate() {
+		if a.MajorUpdate() != "" {
+			explainf(executablePath, "held back: %s available but -allow-major not set", a.MajorUpdate())
+		} else {
+			explainf(executablePath, "up to date at %s (%s)", a.InstalledVersion(), targetReason(a))
+		}
+		runStats.upToDate.Add(1)
+		return a
+	}
+	if !update {
+		explainf(executablePath, "update available: %s -> %s (%s)", a.InstalledVersion(), a.TargetVersion(), targetReason(a))
+		return a
+	}
+	if fixVulnerable && len(vulns) == 0 {
+		log.Info("skipping binary without known vulnerabilities")
+		explainf(executablePath, "skipped: no known vulnerabilities, -fix-vulnerable set")
+		runStats.skipped.Add(1)
+		return a
+	}
+
+	installArtefact(ctx, a, log)
+	return a
+}
+
+// installArtefact updates the resolved artefact unless it is go-update itself,
+// which is deferred, or it can't be replaced, is running or the update is
+// declined. It returns the outcome as printed by -explain.
+func installArtefact(ctx context.Context, a Artefact, log *slog.Logger) string {
+	executablePath := a.ExecutablePath()
+	outcome := func(format string, args ...any) string {
+		explainf(executablePath, format, args...)
+		return fmt.Sprintf(format, args...)
+	}
+
+	if isSelf(executablePath) {
+		// Replacing the running executable is left to the end of the run.
+		log.Info("deferring self-update")
+		return outcome("deferred: go-update replaces itself at the end of the run")
+	}
+
+	if _, ok := a.(*binary); ok && !forceBusy && !dryRun && isBusy(executablePath) {
+		log.Warn("skipping binary which is running, close it or use -force-busy to replace it anyway")
+		runStats.skipped.Add(1)
+		return outcome("skipped: running, -force-busy not set")
+	}
+	if err := notReplaceable(a); err != nil && !forcePermissions {
+		log.Warn("skipping binary the current user can't replace, use -force-permissions to attempt it anyway", internal.AttrErr(err))
+		runStats.skipped.Add(1)
+		return outcome("skipped: %s, -force-permissions not set", err.Error())
+	}
+
+	if showChangelog {
+		printChangelog(ctx, a, log)
+	}
+
+	if !confirmUpdate(a) {
+		log.Info("skipped update on request")
+		runStats.skipped.Add(1)
+		return outcome("skipped: declined")
+	}
+
+	err := updateArtefact(ctx, a)
+	if err != nil {
+		log.Error("installing target version failed", internal.AttrErr(err))
+		runStats.failed.Add(1)
+		return outcome("failed: %s", err.Error())
+	}
+	runStats.updated.Add(1)
+
+	if dryRun {
+		log.Info("skipped update due to dry run")
+		return outcome("would update: %s -> %s (%s)", a.InstalledVersion(), a.TargetVersion(), targetReason(a))
+	}
+	log.Info("updated artefact")
+	reportUpdate(executablePath, a.InstalledVersion(), a.TargetVersion())
+	return outcome("updated: %s -> %s (%s)", a.InstalledVersion(), a.TargetVersion(), targetReason(a))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"moehl.dev/go-update/pkg/update"
+)
+
+// selfPath returns the resolved path of the running executable.
+func selfPath() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// isSelf reports whether path refers to the running executable.
+func isSelf(path string) bool {
+	self, err := selfPath()
+	if err != nil {
+		return false
+	}
+
+	selfInfo, err := os.Stat(self)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(selfInfo, info)
+}
+
+// runSelfUpdate updates the running executable to the latest version of its
+// module.
+func runSelfUpdate(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return usageError{fmt.Errorf("self-update does not accept arguments")}
+	}
+
+	exe, err := selfPath()
+	if err != nil {
+		return fmt.Errorf("locate executable: %w", err)
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return errors.New("executable does not contain build info")
+	}
+	if info.Main.Version == update.DevelVersion {
+		return errors.New("refusing to replace a development build")
+	}
+
+	a, err := update.NewArtefact(ctx, exe, info, artefactOptions(binaryName(filepath.Base(exe))))
+	if err != nil {
+		return err
+	}
+
+	if !a.NeedsUpdate() {
+		fmt.Printf("%s is up to date (%s)\n", a.InstallPath(), a.InstalledVersion())
+		return nil
+	}
+
+	return selfUpdate(ctx, a)
+}
+
+// selfUpdate replaces the running executable with the target version of the
+// artefact. The new binary is installed into a staging directory next to the
+// executable and checked before it is moved into place.
+func selfUpdate(ctx context.Context, a Artefact) (err error) {
+	exe := a.ExecutablePath()
+
+	start := time.Now()
+	defer func() {
+		recordUpdate(exe, a.InstalledVersion(), a.TargetVersion(), start, err)
+	}()
+
+	if dryRun {
+		printDryRun(
+			fmt.Sprintf("GOBIN=<staging> %s", installOptions(a).Command(a.InstallPath(), a.TargetVersion())),
+			fmt.Sprintf("mv <staging>/%s %s", filepath.Base(exe), exe),
+		)
+		return nil
+	}
+
+	// The staging directory has to be on the same file system as the
+	// executable, so it can be replaced atomically.
+	staged, cleanup, err := stageInstall(ctx, filepath.Dir(exe), a.InstallPath(), a.TargetVersion(), installOptions(a))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	err = backup(exe, a.InstalledVersion())
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	err = replaceWithStaged(staged, exe)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(humanOut(), "updated %s from %s to %s\n", a.InstallPath(), a.InstalledVersion(), a.TargetVersion())
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const serviceName = "go-update"
+
+var (
+	// serviceInterval is the time between two scheduled runs.
+	serviceInterval = 24 * time.Hour
+
+	// serviceSystem installs the service for the whole system instead of the
+	// current user.
+	serviceSystem bool
+)
+
+// serviceEnvs are the environment variables passed to the scheduled runs, if
+// they are set. GOBIN is always passed, resolved like go-update does.
+var serviceEnvs = []string{
+	"PATH",
+	goProxyEnv,
+	"GOPRIVATE",
+	"GONOPROXY",
+	"GONOSUMDB",
+	"GOFLAGS",
+	netrcEnv,
+	caFileEnv,
+	clientCertEnv,
+	clientKeyEnv,
+	goMinVersionEnv,
+	jobsEnv,
+	cacheTTLEnv,
+	retriesEnv,
+	retryDelayEnv,
+	timeoutEnv,
+	rateLimitEnv,
+	toolchainURLEnv,
+	cooldownEnv,
+	binDirsEnv,
+	goCliEnv,
+	"LOG",
+}
+
+type serviceFile struct {
+	path    string
+	content string
+}
+
+// serviceManager generates and installs the files scheduling go-update on a
+// platform.
+type serviceManager interface {
+	// files returns the files running the executable with the environment on
+	// a schedule.
+	files(exe string, env [][2]string) ([]serviceFile, error)
+	// install activates the written files.
+	install() [][]string
+	// uninstall deactivates the service before its files are removed.
+	uninstall() [][]string
+}
+
+// runService prints, installs or uninstalls the files scheduling go-update.
+func runService(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError{fmt.Errorf("service requires one of print, install or uninstall")}
+	}
+	if serviceInterval < time.Minute {
+		return usageError{fmt.Errorf("-interval must be at least 1m")}
+	}
+
+	var m serviceManager
+	switch runtime.GOOS {
+	case "linux":
+		m = systemd{system: serviceSystem}
+	case "darwin":
+		m = launchd{system: serviceSystem}
+	default:
+		return fmt.Errorf("services are not supported on %s", runtime.GOOS)
+	}
+
+	exe, err := selfPath()
+	if err != nil {
+		return err
+	}
+
+	env := [][2]string{{goBinEnv, goBin}}
+	for _, name := range serviceEnvs {
+		if v, ok := os.LookupEnv(name); ok {
+			env = append(env, [2]string{name, v})
+		}
+	}
+
+	files, err := m.files(exe, env)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "print":
+		for _, f := range files {
+			fmt.Printf("# %s\n%s\n", f.path, f.content)
+		}
+		return nil
+	case "install":
+		for _, f := range files {
+			err = writeServiceFile(f)
+			if err != nil {
+				return err
+			}
+		}
+		return runServiceCommands(m.install())
+	case "uninstall":
+		err = runServiceCommands(m.uninstall())
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if dryRun {
+				printDryRun("rm " + f.path)
+				continue
+			}
+			err = os.Remove(f.path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		return nil
+	default:
+		return usageError{fmt.Errorf("unknown service action '%s'", args[0])}
+	}
+}
+
+func writeServiceFile(f serviceFile) error {
+	if dryRun {
+		printDryRun(fmt.Sprintf("cat > %s <<EOF\n%sEOF", f.path, f.content))
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(f.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(f.path, []byte(f.content), 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %s\n", f.path)
+
+	return nil
+}
+
+func runServiceCommands(cmds [][]string) error {
+	for _, args := range cmds {
+		if dryRun {
+			printDryRun(strings.Join(args, " "))
+			continue
+		}
+
+		c := exec.Command(args[0], args[1:]...)
+		c.Stdout, c.Stderr = os.Stdout, os.Stderr
+		err := c.Run()
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(args, " "), err)
+		}
+	}
+	return nil
+}
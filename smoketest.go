@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// smokeTest runs updated binaries with a probe like --version and restores the
+// previous version if it fails.
+var smokeTest bool
+
+// probeTimeout limits the time a single probe may run, so a binary waiting for
+// input or a server started by mistake doesn't stall the update.
+const probeTimeout = 10 * time.Second
+
+// defaultProbes are tried in order if no probe is configured for a binary. The
+// binary passes if one of them succeeds, as not every binary has a --version
+// flag.
+var defaultProbes = [][]string{{"--version"}, {"-h"}}
+
+// smokeTestEnabled reports whether updated binaries are smoke tested.
+func smokeTestEnabled() bool {
+	return smokeTest || cfg.SmokeTest
+}
+
+// probes returns the argument lists the binary with the given name is run with
+// by the smoke test.
+func probes(name string) [][]string {
+	bc, ok := cfg.Binaries[name]
+	if ok && bc.Probe != nil {
+		return [][]string{bc.Probe}
+	}
+	return defaultProbes
+}
+
+// probeCommand returns the command line of the first probe of the binary, as
+// printed during a dry run.
+func probeCommand(executablePath string) string {
+	return strings.Join(append([]string{executablePath}, probes(binaryName(filepath.Base(executablePath)))[0]...), " ")
+}
+
+// runSmokeTest runs the binary with its probes until one of them exits
+// successfully. A non-zero exit code, a crash or exceeding probeTimeout fails
+// a probe.
+func runSmokeTest(ctx context.Context, executablePath string) error {
+	var errs []error
+	for _, args := range probes(binaryName(filepath.Base(executablePath))) {
+		err := runProbe(ctx, executablePath, args)
+		if err == nil {
+			slog.Debug("smoke test passed", "path", executablePath, "args", args)
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("smoke test failed: %w", errors.Join(errs...))
+}
+
+func runProbe(ctx context.Context, executablePath string, args []string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, executablePath, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s: timed out after %s", strings.Join(args, " "), probeTimeout)
+	} else if err != nil {
+		return fmt.Errorf("%s: %w: %s", strings.Join(args, " "), err, firstLine(out.String()))
+	}
+	return nil
+}
+
+// firstLine returns the first line of the output of a failed probe, which
+// usually contains the reason, e.g. an unknown flag or a panic.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"debug/buildinfo"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"moehl.dev/go-update/internal"
+	"moehl.dev/go-update/pkg/update"
+)
+
+// stageInstall installs the version of the package into a new staging
+// directory in dir and checks the version of the installed binary. It returns
+// the path of the binary and a function removing the staging directory.
+func stageInstall(ctx context.Context, dir, pkg, version string, opts update.InstallOptions) (string, func(), error) {
+	staging, err := os.MkdirTemp(dir, ".go-update-staging-")
+	if err != nil {
+		return "", nil, fmt.Errorf("create staging directory: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(staging) }
+
+	staged, err := installStaged(ctx, staging, pkg, version, opts)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return staged, cleanup, nil
+}
+
+// sharedBuild is a binary installed during this run, see stageBuild.
+type sharedBuild struct {
+	once sync.Once
+	// done is closed once the first install with the command has finished.
+	done chan struct{}
+	// path is the binary installed by it, empty if it failed.
+	path string
+}
+
+var (
+	installedBuildsMu sync.Mutex
+	// installedBuilds maps install commands to the first binary installed by
+	// them during this run. Further binaries installed from the same package
+	// with the same command, like a copy under another name, wait for it and
+	// are copied from it instead of being built again.
+	installedBuilds = make(map[string]*sharedBuild)
+)
+
+// stageBuild stages the version of the package like stageInstall. If another
+// binary is installed with the same command during this run, it waits for that
+// install to finish and copies the binary. The caller has to report the
+// outcome with finishBuild.
+func stageBuild(ctx context.Context, dir, pkg, version string, opts update.InstallOptions) (string, func(), error) {
+	installedBuildsMu.Lock()
+	build, ok := installedBuilds[opts.Command(pkg, version)]
+	if !ok {
+		installedBuilds[opts.Command(pkg, version)] = &sharedBuild{done: make(chan struct{})}
+	}
+	installedBuildsMu.Unlock()
+	if !ok {
+		return stageInstall(ctx, dir, pkg, version, opts)
+	}
+
+	select {
+	case <-build.done:
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	}
+	if build.path == "" {
+		return stageInstall(ctx, dir, pkg, version, opts)
+	}
+
+	staging, err := os.MkdirTemp(dir, ".go-update-staging-")
+	if err != nil {
+		return "", nil, fmt.Errorf("create staging directory: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(staging) }
+
+	staged := filepath.Join(staging, filepath.Base(build.path))
+	err = copyFile(build.path, staged)
+	if err != nil {
+		cleanup()
+		slog.Debug("copying installed build failed, building it again", "src", build.path, internal.AttrErr(err))
+		return stageInstall(ctx, dir, pkg, version, opts)
+	}
+
+	slog.Debug("copied installed build", "src", build.path)
+	return staged, cleanup, nil
+}
+
+// finishBuild reports the outcome of an install staged with stageBuild, the
+// path of the installed binary or the empty string if it failed. Only the
+// outcome of the first install with the command is kept.
+func finishBuild(executablePath, pkg, version string, opts update.InstallOptions) {
+	installedBuildsMu.Lock()
+	build := installedBuilds[opts.Command(pkg, version)]
+	installedBuildsMu.Unlock()
+	if build == nil {
+		return
+	}
+
+	build.once.Do(func() {
+		build.path = executablePath
+		close(build.done)
+	})
+}
+
+func installStaged(ctx context.Context, staging, pkg, version string, opts update.InstallOptions) (string, error) {
+	err := update.Updater{Dir: staging}.Install(ctx, pkg, version, opts)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) != 1 {
+		return "", fmt.Errorf("expected one binary in staging directory, found %d", len(entries))
+	}
+	staged := filepath.Join(staging, entries[0].Name())
+
+	bi, err := buildinfo.ReadFile(staged)
+	if err != nil {
+		return "", fmt.Errorf("read build info of staged binary: %w", err)
+	}
+	if bi.Path != pkg {
+		return "", fmt.Errorf("staged binary is %s instead of %s", bi.Path, pkg)
+	}
+	if bi.Main.Version != version {
+		return "", fmt.Errorf("staged binary has version %s instead of %s", bi.Main.Version, version)
+	}
+
+	return staged, nil
+}
+
+// forceBusy replaces binaries even if they are running.
+var forceBusy bool
+
+// isBusy reports whether the executable at path is running. Opening it for
+// writing without truncating it fails if it is, at least on Linux and Windows.
+// Other systems allow it, so executables are never reported busy there.
+func isBusy(path string) bool {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return isBusyError(err)
+	}
+	_ = f.Close()
+	return false
+}
+
+// forcePermissions attempts to replace binaries even if the current user
+// doesn't own them or can't write to their directory.
+var forcePermissions bool
+
+// notReplaceable returns why the current user can't replace the artefact, or
+// nil if it can. Only binaries are checked, toolchains are installed next to
+// the existing ones.
+func notReplaceable(a Artefact) error {
+	if _, ok := a.(*binary); !ok {
+		return nil
+	}
+	return checkReplaceable(a.ExecutablePath())
+}
+
+// replaceWithStaged moves the staged binary over the one at dst. The rename is
+// atomic, so dst is either the old or the new binary. On Windows, where the old
+// binary has to be moved aside first, it is restored if the rename fails.
+func replaceWithStaged(staged, dst string) error {
+	done, err := prepareReplace(dst)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(staged, dst)
+	done(err == nil)
+	if isBusyError(err) {
+		return fmt.Errorf("%s is running, close it and try again: %w", dst, err)
+	}
+	return err
+}
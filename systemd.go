@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// systemd schedules go-update with a systemd service and timer.
+type systemd struct {
+	// system installs the units for the whole system, they are run as the
+	// current user.
+	system bool
+}
+
+func (s systemd) unitDir() (string, error) {
+	if s.system {
+		return "/etc/systemd/system", nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user"), nil
+}
+
+func (s systemd) files(exe string, env [][2]string) ([]serviceFile, error) {
+	dir, err := s.unitDir()
+	if err != nil {
+		return nil, err
+	}
+
+	service := strings.Builder{}
+	service.WriteString("[Unit]\nDescription=Update the binaries in GOBIN\n")
+	service.WriteString("Wants=network-online.target\nAfter=network-online.target\n\n")
+	service.WriteString("[Service]\nType=oneshot\n")
+	if s.system {
+		u, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		service.WriteString("User=" + u.Username + "\n")
+	}
+	for _, e := range env {
+		service.WriteString("Environment=" + systemdQuote(e[0]+"="+e[1]) + "\n")
+	}
+	service.WriteString("ExecStart=" + systemdQuote(exe) + " update\n")
+
+	timer := strings.Builder{}
+	timer.WriteString("[Unit]\nDescription=Update the binaries in GOBIN periodically\n\n")
+	timer.WriteString("[Timer]\nOnBootSec=15min\n")
+	timer.WriteString(fmt.Sprintf("OnUnitActiveSec=%ds\n", int(serviceInterval/time.Second)))
+	timer.WriteString("RandomizedDelaySec=10min\n\n")
+	timer.WriteString("[Install]\nWantedBy=timers.target\n")
+
+	return []serviceFile{
+		{path: filepath.Join(dir, serviceName+".service"), content: service.String()},
+		{path: filepath.Join(dir, serviceName+".timer"), content: timer.String()},
+	}, nil
+}
+
+func (s systemd) systemctl(args ...string) []string {
+	if s.system {
+		return append([]string{"systemctl"}, args...)
+	}
+	return append([]string{"systemctl", "--user"}, args...)
+}
+
+func (s systemd) install() [][]string {
+	return [][]string{
+		s.systemctl("daemon-reload"),
+		s.systemctl("enable", "--now", serviceName+".timer"),
+	}
+}
+
+func (s systemd) uninstall() [][]string {
+	return [][]string{
+		s.systemctl("disable", "--now", serviceName+".timer"),
+	}
+}
+
+// systemdQuote quotes a value of a unit file setting, so whitespace, quotes
+// and specifiers are taken literally.
+func systemdQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%").Replace(s)
+	return `"` + s + `"`
+}
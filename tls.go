@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+const (
+	caFileEnv             = "GOUPDATE_CA_FILE"
+	clientCertEnv         = "GOUPDATE_CLIENT_CERT"
+	clientKeyEnv          = "GOUPDATE_CLIENT_KEY"
+	insecureSkipVerifyEnv = "GOUPDATE_INSECURE_SKIP_VERIFY"
+)
+
+// newTransport creates the transport of the HTTP client with the TLS settings
+// from the environment: additional root CAs, a client certificate and, only if
+// explicitly requested, disabled certificate verification. They only apply to
+// requests made by go-update itself, not to the go command.
+func newTransport() (http.RoundTripper, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	caFile := os.Getenv(caFileEnv)
+	certFile, keyFile := os.Getenv(clientCertEnv), os.Getenv(clientKeyEnv)
+	skipVerify := false
+	if v, ok := os.LookupEnv(insecureSkipVerifyEnv); ok {
+		var err error
+		skipVerify, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("parse $%s: %w", insecureSkipVerifyEnv, err)
+		}
+	}
+
+	if caFile == "" && certFile == "" && keyFile == "" && !skipVerify {
+		return t, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read $%s: %w", caFileEnv, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("$%s (%s) contains no certificates", caFileEnv, caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("$%s and $%s must be set together", clientCertEnv, clientKeyEnv)
+		}
+
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if skipVerify {
+		slog.Warn("TLS certificate verification is disabled", "env", insecureSkipVerifyEnv)
+		cfg.InsecureSkipVerify = true
+	}
+
+	t.TLSClientConfig = cfg
+
+	return t, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"moehl.dev/go-update/internal"
+)
+
+// toolchainURL is the download page listing the go releases. It can be
+// replaced by a mirror of go.dev/dl serving the same JSON format.
+var toolchainURL = "https://go.dev/dl/"
+
+// goRelease is a go release as listed by go.dev/dl.
+type goRelease struct {
+	Version string          `json:"version"`
+	Stable  bool            `json:"stable"`
+	Files   []goReleaseFile `json:"files"`
+}
+
+// goReleaseFile is a file of a go release, e.g. the SDK archive for one
+// platform.
+type goReleaseFile struct {
+	Filename string `json:"filename"`
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	SHA256   string `json:"sha256"`
+	Kind     string `json:"kind"`
+}
+
+// goReleases returns all go releases, including unstable and archived ones.
+func goReleases(ctx context.Context) ([]goRelease, error) {
+	u, err := url.Parse(toolchainURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse toolchain url: %w", err)
+	}
+	q := u.Query()
+	q.Set("mode", "json")
+	q.Set("include", "all")
+	u.RawQuery = q.Encode()
+
+	body, err := goDevGet(ctx, u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []goRelease
+	err = json.Unmarshal(body, &releases)
+	return releases, err
+}
+
+// latestInSeries returns the newest stable release of the series, e.g. 1.22,
+// or of all series if it is empty. It returns the empty string if there is
+// none.
+func latestInSeries(releases []goRelease, series string) string {
+	prefix := "go" + strings.TrimPrefix(series, "go")
+
+	latest := ""
+	for _, r := range releases {
+		if !r.Stable || (series != "" && r.Version != prefix && !strings.HasPrefix(r.Version, prefix+".")) {
+			continue
+		}
+		if latest == "" || internal.CompareGoVersions(r.Version, latest) > 0 {
+			latest = r.Version
+		}
+	}
+	return latest
+}
+
+// sdkArchive returns the SDK archive of the version for the current platform,
+// which is the one golang.org/dl downloads.
+func sdkArchive(releases []goRelease, version string) (goReleaseFile, bool) {
+	for _, r := range releases {
+		if r.Version != version {
+			continue
+		}
+		for _, f := range r.Files {
+			if f.Kind == "archive" && f.OS == runtime.GOOS && f.Arch == runtime.GOARCH {
+				return f, true
+			}
+		}
+	}
+	return goReleaseFile{}, false
+}
+
+// verifySDK checks the SHA256 sum of the archive golang.org/dl downloaded into
+// the SDK directory of the version. If it does not match, the SDK is removed so
+// it can't be used. If the archive is not there anymore, it can't be verified,
+// which is only logged.
+func verifySDK(version string, archive goReleaseFile) error {
+	dir, err := sdkDir()
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(dir, version, archive.Filename)
+
+	sum, err := fileSHA256(p)
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Warn("SDK archive not found, unable to verify it", "path", p)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("hash %s: %w", p, err)
+	}
+
+	if sum != archive.SHA256 {
+		_ = os.RemoveAll(filepath.Join(dir, version))
+		return fmt.Errorf("SHA256 of %s is %s, go.dev announced %s", p, sum, archive.SHA256)
+	}
+
+	slog.Debug("verified SDK archive", "path", p, "sha256", sum)
+	return nil
+}
+
+// fileSHA256 returns the hex encoded SHA256 sum of the file.
+func fileSHA256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// toolchainSeries returns the minor version of a go version, e.g. 1.22 for
+// go1.22.7 or go1.23rc1.
+func toolchainSeries(version string) string {
+	v := strings.TrimPrefix(version, "go")
+	if i := strings.IndexAny(v, "abcdefghijklmnopqrstuvwxyz"); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.SplitN(v, ".", 3)
+	return strings.Join(parts[:min(len(parts), 2)], ".")
+}
+
+// goDevGet requests the url on go.dev or its mirror, retrying transient
+// failures.
+func goDevGet(ctx context.Context, url string) ([]byte, error) {
+	var body []byte
+	err := internal.Retry(ctx, url, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = res.Body.Close() }()
+
+		if res.StatusCode != http.StatusOK {
+			return &internal.StatusError{URL: url, Status: res.Status, Code: res.StatusCode}
+		}
+
+		body, err = io.ReadAll(res.Body)
+		return err
+	})
+	return body, err
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	tuiHelp = "↑/↓ move  space select  a select outdated  / filter  u update  q quit"
+
+	// lines used by the header and the table header
+	tuiHeaderLines = 3
+)
+
+// tuiRow is an artefact shown in the terminal UI.
+type tuiRow struct {
+	a        Artefact
+	name     string
+	selected bool
+	// status of an update triggered from the UI
+	status string
+	busy   bool
+}
+
+// tuiStatus reports the progress of an update to the UI loop.
+type tuiStatus struct {
+	row    *tuiRow
+	status string
+	done   bool
+}
+
+type tui struct {
+	rows      []*tuiRow
+	cursor    int
+	offset    int
+	filter    string
+	filtering bool
+	running   int
+	quitting  bool
+	// queued are the artefacts updates have been started for, updating
+	// go-update itself is deferred until the UI is closed.
+	queued []Artefact
+
+	out    *bufio.Writer
+	status chan tuiStatus
+	// ctx aborts the updates started from the UI.
+	ctx context.Context
+}
+
+// runTUI shows a full-screen terminal UI to browse the artefacts, select and
+// update them.
+func runTUI(ctx context.Context, names []string) error {
+	inFd, outFd := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(inFd) || !term.IsTerminal(outFd) {
+		return errors.New("tui requires a terminal")
+	}
+
+	// Log records and dry run output would garble the screen, they are
+	// printed once the UI is closed.
+	logs := &recordBuffer{}
+	prevLogger := slog.Default()
+	slog.SetDefault(slog.New(&bufferedHandler{Handler: prevLogger.Handler(), buf: logs}))
+	dryRunBuf := &bytes.Buffer{}
+	dryRunOut = dryRunBuf
+	defer func() {
+		slog.SetDefault(prevLogger)
+		logs.flush()
+		dryRunOut = os.Stdout
+		_, _ = io.Copy(os.Stdout, dryRunBuf)
+	}()
+
+	fmt.Println("Loading artefacts...")
+	artefacts, err := loadArtefacts(ctx, names, false)
+	if err != nil {
+		return err
+	}
+
+	t := &tui{
+		out:    bufio.NewWriter(os.Stdout),
+		status: make(chan tuiStatus),
+		ctx:    ctx,
+	}
+	for _, a := range artefacts {
+		t.rows = append(t.rows, &tuiRow{a: a, name: filepath.Base(a.ExecutablePath())})
+	}
+
+	err = t.run(ctx, inFd, outFd)
+	if err != nil {
+		return err
+	}
+
+	return updateSelf(ctx, t.queued)
+}
+
+// run shows the UI until it is closed and all updates started from it are
+// done.
+func (t *tui) run(ctx context.Context, inFd, outFd int) error {
+	state, err := term.MakeRaw(inFd)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = term.Restore(inFd, state) }()
+
+	// switch to the alternate screen and hide the cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+
+	for {
+		t.render(outFd)
+
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				t.quitting = true
+			} else {
+				t.handleKey(key)
+			}
+		case s := <-t.status:
+			s.row.status = s.status
+			if s.done {
+				s.row.busy = false
+				t.running--
+			}
+		case <-ctx.Done():
+			t.quitting = true
+		}
+
+		if t.quitting && t.running == 0 {
+			return nil
+		}
+	}
+}
+
+// readKeys sends every key press read from r, escape sequences are sent as a
+// whole.
+func readKeys(r io.Reader, keys chan<- string) {
+	defer close(keys)
+
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		keys <- string(buf[:n])
+	}
+}
+
+// visible returns the rows matching the filter.
+func (t *tui) visible() []*tuiRow {
+	if t.filter == "" {
+		return t.rows
+	}
+
+	filter := strings.ToLower(t.filter)
+	var rows []*tuiRow
+	for _, r := range t.rows {
+		if strings.Contains(strings.ToLower(r.name), filter) ||
+			strings.Contains(strings.ToLower(r.a.InstallPath()), filter) {
+			rows = append(rows, r)
+		}
+	}
+	return rows
+}
+
+func (t *tui) handleKey(key string) {
+	if t.filtering {
+		switch key {
+		case "\r", "\n":
+			t.filtering = false
+		case "\x1b":
+			t.filtering = false
+			t.filter = ""
+		case "\x7f", "\b":
+			if len(t.filter) > 0 {
+				t.filter = t.filter[:len(t.filter)-1]
+			}
+		default:
+			if len(key) == 1 && key[0] >= ' ' {
+				t.filter += key
+			}
+		}
+		t.cursor = 0
+		return
+	}
+
+	rows := t.visible()
+	switch key {
+	case "k", "\x1b[A", "\x1bOA":
+		if t.cursor > 0 {
+			t.cursor--
+		}
+	case "j", "\x1b[B", "\x1bOB":
+		if t.cursor < len(rows)-1 {
+			t.cursor++
+		}
+	case " ":
+		if t.cursor < len(rows) {
+			rows[t.cursor].selected = !rows[t.cursor].selected
+		}
+	case "a":
+		for _, r := range rows {
+			r.selected = r.a.NeedsUpdate()
+		}
+	case "/":
+		t.filtering = true
+	case "u", "\r":
+		t.update(rows)
+	case "q", "\x03":
+		t.quitting = true
+	}
+}
+
+// update starts updating the selected rows, or the one under the cursor if none
+// are selected, in the background.
+func (t *tui) update(rows []*tuiRow) {
+	var selected []*tuiRow
+	for _, r := range rows {
+		if r.selected {
+			selected = append(selected, r)
+		}
+	}
+	if len(selected) == 0 && t.cursor < len(rows) {
+		selected = append(selected, rows[t.cursor])
+	}
+
+	var queued []*tuiRow
+	for _, r := range selected {
+		r.selected = false
+		if r.busy {
+			continue
+		}
+		if !r.a.NeedsUpdate() {
+			r.status = "up to date"
+			continue
+		}
+
+		r.busy = true
+		r.status = "queued"
+		queued = append(queued, r)
+		t.queued = append(t.queued, r.a)
+	}
+
+	t.running += len(queued)
+	go parallel(jobs, len(queued), func(i int) {
+		r := queued[i]
+		t.status <- tuiStatus{row: r, status: "updating"}
+
+		status := installArtefact(t.ctx, r.a, slog.With("path", r.a.ExecutablePath()))
+		t.status <- tuiStatus{row: r, status: status, done: true}
+	})
+}
+
+func (t *tui) render(fd int) {
+	width, height, err := term.GetSize(fd)
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+
+	rows := t.visible()
+	if t.cursor >= len(rows) {
+		t.cursor = len(rows) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+
+	// scroll the cursor into view
+	pageSize := height - tuiHeaderLines
+	if pageSize < 1 {
+		pageSize = 1
+	}
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	} else if t.cursor >= t.offset+pageSize {
+		t.offset = t.cursor - pageSize + 1
+	}
+
+	table := [][]string{{"", "Program", "Installed Version", "Latest Version", "Status"}}
+	for _, r := range rows {
+		mark := "[ ]"
+		if r.selected {
+			mark = "[x]"
+		}
+		target := r.a.TargetVersion()
+		if r.a.Pinned() {
+			target += " (pinned)"
+		}
+		table = append(table, []string{mark, r.name, installedLabel(r.a), target, r.status})
+	}
+	lines := tableLines(table)
+
+	// Move the cursor home and clear the screen. In raw mode a newline does
+	// not include a carriage return.
+	_, _ = t.out.WriteString("\x1b[H\x1b[2J")
+	header := tuiHelp
+	if t.filtering || t.filter != "" {
+		header = "Filter: " + t.filter
+		if t.filtering {
+			header += "_"
+		}
+	}
+	if t.quitting {
+		header = fmt.Sprintf("Waiting for %d updates to finish...", t.running)
+	}
+	t.writeLine(header, width, false)
+	t.writeLine("", width, false)
+	t.writeLine(lines[0], width, false)
+
+	for i := t.offset; i < len(rows) && i < t.offset+pageSize; i++ {
+		t.writeLine(lines[i+1], width, i == t.cursor)
+	}
+
+	_ = t.out.Flush()
+}
+
+func (t *tui) writeLine(line string, width int, highlight bool) {
+	if len(line) > width {
+		line = line[:width]
+	}
+	if highlight {
+		line = "\x1b[7m" + line + strings.Repeat(" ", width-len(line)) + "\x1b[0m"
+	}
+	_, _ = t.out.WriteString(line + "\r\n")
+}
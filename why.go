@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"moehl.dev/go-update/pkg/update"
+)
+
+// runWhy explains how the target version of a binary is chosen and whether it
+// would be updated: the ignore patterns and options applying to it, the
+// versions of its module and why candidates have been rejected.
+func runWhy(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return usageError{fmt.Errorf("why requires exactly one binary")}
+	}
+	showMajor = true
+
+	entries, err := readBinDirs()
+	if err != nil {
+		return err
+	}
+	entries, err = selectEntries(entries, args)
+	if err != nil {
+		return err
+	}
+	entry := entries[0]
+	name := binaryName(entry.Name())
+
+	fmt.Printf("binary %s\n", entry.path())
+
+	if !explainIgnore(entry.Name()) {
+		return nil
+	}
+
+	bi, err := update.Scanner{MinGoVersion: minGoVersion}.Read(entry.path())
+	if err != nil {
+		fmt.Printf("skipped, %s\n", err.Error())
+		return nil
+	}
+	fmt.Printf("built from %s in module %s at %s with %s\n", bi.Path, bi.Main.Path, bi.Main.Version, bi.GoVersion)
+
+	opts := artefactOptions(name)
+	explainOptions(opts)
+
+	a, err := update.NewArtefact(ctx, entry.path(), bi, opts)
+	if err != nil {
+		return fmt.Errorf("resolve target version: %w", err)
+	}
+
+	b, ok := a.(*binary)
+	switch {
+	case !ok:
+		fmt.Println("go toolchain wrapper, the target is the latest go release of its series")
+	case b.res.Requested:
+		fmt.Printf("version %s has been requested explicitly\n", opts.Version)
+	case b.res.Pinned:
+		fmt.Printf("pinned to %s in %s\n", b.res.Target, filepath.Join(goBin, pinsPath))
+	case b.res.Branch != "":
+		branch := b.res.Branch
+		if branch == update.DefaultBranch {
+			branch = "the default branch"
+		}
+		fmt.Printf("installed at a pseudo-version, the target is the latest commit on %s\n", branch)
+	default:
+		explainVersions(bi.Main.Path, b.res)
+	}
+
+	explainDecision(a)
+
+	return nil
+}
+
+// explainIgnore prints the ignore patterns matching the binary and reports
+// whether it is processed.
+func explainIgnore(name string) bool {
+	excluded := matchingPatterns(excludePatterns, name)
+	if len(excluded) == 0 {
+		fmt.Println("not matched by any ignore pattern")
+		return true
+	}
+
+	included := matchingPatterns(includePatterns, name)
+	ignoreFile := filepath.Join(goBin, ignorePath)
+	if ignore(excludePatterns, includePatterns, name) {
+		fmt.Printf("ignored, matched by %s in %s\n", strings.Join(excluded, ", "), ignoreFile)
+		return false
+	}
+	fmt.Printf("matched by %s but included again by %s in %s\n", strings.Join(excluded, ", "), strings.Join(included, ", "), ignoreFile)
+	return true
+}
+
+// explainOptions prints the options affecting the resolution of the target
+// version which are set.
+func explainOptions(opts ArtefactOptions) {
+	var set []string
+	add := func(enabled bool, option string) {
+		if enabled {
+			set = append(set, option)
+		}
+	}
+	add(opts.Constraint != "", "constraint "+opts.Constraint)
+	add(opts.Branch != "", "branch "+opts.Branch)
+	add(opts.Cooldown > 0, "cooldown "+opts.Cooldown.String())
+	add(opts.Prerelease, "prereleases allowed")
+	add(opts.AllowMajor, "major updates allowed")
+	add(opts.AdoptDevel, "development builds adopted")
+	add(opts.AllowDowngrade, "downgrades allowed")
+	add(opts.AllowReplaced, "replace directives may be discarded")
+
+	if len(set) > 0 {
+		fmt.Printf("options: %s\n", strings.Join(set, ", "))
+	}
+}
+
+// explainVersions prints the versions of the module not older than the
+// installed one and why each of them has been rejected or accepted as target
+// version by the resolver.
+func explainVersions(modulePath string, res *update.Resolution) {
+	if res.Candidates == nil {
+		fmt.Printf("versions of %s unknown, development builds are not updated\n", modulePath)
+		return
+	}
+	fmt.Printf("%d versions listed for %s\n", len(res.Candidates), modulePath)
+
+	older := 0
+	for _, c := range res.Candidates {
+		if update.IsDowngrade(res.Installed, c.Version) {
+			older++
+			continue
+		}
+
+		var verdict string
+		switch {
+		case c.Rejected == "" && c.Version == res.Installed:
+			verdict = "installed, target"
+		case c.Rejected == "":
+			verdict = "target"
+		case c.Version == res.Installed:
+			verdict = "installed"
+		default:
+			verdict = "rejected, " + c.Rejected
+		}
+		fmt.Printf("  %-20s %s\n", c.Version, verdict)
+	}
+
+	if older > 0 {
+		fmt.Printf("  %d older versions\n", older)
+	}
+	if !slices.ContainsFunc(res.Candidates, func(c update.Candidate) bool { return c.Rejected == "" }) && !res.Major {
+		fmt.Printf("no listed version qualifies, %s is the latest version according to the module proxy\n", res.Target)
+	}
+}
+
+// explainDecision prints the target version and whether the binary would be
+// updated to it.
+func explainDecision(a Artefact) {
+	fmt.Printf("target %s\n", a.TargetVersion())
+	if a.MajorUpdate() != "" {
+		fmt.Printf("newer major version %s is available, allow it with -allow-major\n", a.MajorUpdate())
+	}
+
+	switch {
+	case a.NeedsUpdate():
+		fmt.Printf("would be updated from %s to %s\n", a.InstalledVersion(), a.TargetVersion())
+	case isDevel(a):
+		fmt.Println("not updated, development build, replace it with -adopt-devel")
+	case len(replaced(a)) > 0 && a.TargetVersion() != a.InstalledVersion():
+		fmt.Printf("not updated, built with replace directives %s, update it anyway with -allow-replaced\n", strings.Join(replaced(a), ", "))
+	case isDowngrade(a):
+		fmt.Println("not updated, the target is older than the installed version, install it anyway with -allow-downgrade")
+	default:
+		fmt.Println("up to date")
+		return
+	}
+
+	if !a.NeedsUpdate() {
+		return
+	}
+	if err := notReplaceable(a); err != nil {
+		fmt.Printf("but the current user can't replace it: %s\n", err.Error())
+	}
+	if found := shadowedBy(a.ExecutablePath()); found != "" {
+		fmt.Printf("but it is shadowed by %s earlier in PATH\n", found)
+	}
+}